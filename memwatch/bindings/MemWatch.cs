@@ -106,6 +106,8 @@ namespace MemWatch {
             public uint mprotect_page_count;
             public uint worker_thread_id;
             public ulong worker_cycles;
+            public uint ring_occupancy;
+            public uint ring_capacity;
         }
         
         public class MemWatchStats {
@@ -0,0 +1,1515 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unsafe"
+	
+	"github.com/cespare/xxhash/v2"
+)
+
+// Severity classifies how much a change in a region matters
+type Severity int
+
+const (
+	Info Severity = iota
+	Warn
+	Critical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "INFO"
+	case Warn:
+		return "WARN"
+	case Critical:
+		return "CRITICAL"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// MemoryEvent is a change detected in a watched region. DetectedAt is the
+// time of the DetectChanges call that found it; it was added after the
+// other fields, so code building events by hand may leave it zero.
+type MemoryEvent struct {
+	Name       string            `json:"name"`
+	Offset     int               `json:"offset"`
+	OldValue   int               `json:"old_value"`
+	NewValue   int               `json:"new_value"`
+	Severity   Severity          `json:"severity"`
+	DetectedAt time.Time         `json:"detected_at"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Stack      []uintptr         `json:"stack,omitempty"`
+}
+
+// StackFrames resolves the call stack captured with SetCaptureStack. It
+// returns nil when no stack was captured.
+func (e MemoryEvent) StackFrames() []runtime.Frame {
+	if len(e.Stack) == 0 {
+		return nil
+	}
+	
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(e.Stack)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, frame)
+		if !more {
+			return frames
+		}
+	}
+}
+
+// RegionInfo describes a watched region. Size is in bytes for regions
+// registered with Watch and in elements for WatchInts.
+type RegionInfo struct {
+	ID          int
+	Name        string
+	Size        int
+	ChangeCount int
+	Throttled   int
+}
+
+// tokenBucket limits a region to rate events per second, allowing bursts
+// of up to rate events
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// take spends a token if one is available at now
+func (b *tokenBucket) take(now time.Time) bool {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+	}
+	b.last = now
+	
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Diff marks an offset a comparator considers changed
+type Diff struct {
+	Offset int
+}
+
+// ResizeEvent reports that a region was replaced by one of another length,
+// e.g. by reassigning its slice. The region is re-baselined at NewLen, in
+// bytes or, for WatchInts regions, elements.
+type ResizeEvent struct {
+	RegionID int
+	OldLen   int
+	NewLen   int
+}
+
+// SignatureEvent reports that a byte region matched a signature registered
+// with AddSignature
+type SignatureEvent struct {
+	Name     string
+	RegionID int
+	Offset   int
+}
+
+// signature is a byte pattern; bytes where mask is false match anything
+type signature struct {
+	name    string
+	pattern []byte
+	mask    []bool
+}
+
+// subscriber is one Subscribe channel and the events it has missed
+type subscriber struct {
+	ch      chan MemoryEvent
+	dropped uint64
+}
+
+// structField is a field of a watched struct; name is appended to the
+// region's name in events, e.g. ".Timeout"
+type structField struct {
+	name   string
+	offset int
+	size   int
+}
+
+type MemoryTracker struct {
+	regions      map[int][]byte
+	initial      map[int][]byte
+	original     map[int][]byte
+	redundant    map[int]int
+	rebased      map[int]bool
+	dirty        map[int]bool
+	restoreCb    func(regionID int)
+	intRegions   map[int][]int
+	intInitial   map[int][]int
+	names        map[int]string
+	changeCounts map[int]int
+	hashes       map[int]uint64
+	severities   map[int]Severity
+	ranges       map[int][][2]int
+	fields       map[int][]structField
+	elemSizes    map[int]int
+	ignored      map[int]map[int]bool
+	segments     map[int][][]byte
+	placements   map[int][][2]int
+	tags         map[int]map[string]string
+	limits       map[int]*tokenBucket
+	throttled    map[int]int
+	heat         map[int]map[int]int
+	comparators  map[int]func(old, new []byte) []Diff
+	granularity  map[int]int
+	consistent   bool
+	regionMu     sync.Mutex
+	snapshots    map[int][]byte
+	intSnapshots map[int][]int
+	signatures   []signature
+	sigEvents    []SignatureEvent
+	resizes      []ResizeEvent
+	hashDetection bool
+	hasher       func() hash.Hash64
+	budget       int
+	budgetCb     func(total int)
+	overBudget   bool
+	dedupPass    bool
+	passEvents   map[[2]int]int
+	now          func() time.Time
+	detectedAt   time.Time
+	captureStack bool
+	stack        []uintptr
+	logf         func(format string, args ...interface{})
+	subMu        sync.Mutex
+	subs         []*subscriber
+	events       []MemoryEvent
+	regionCount  int
+}
+
+func NewMemoryTracker() *MemoryTracker {
+	return &MemoryTracker{
+		regions:      make(map[int][]byte),
+		initial:      make(map[int][]byte),
+		original:     make(map[int][]byte),
+		redundant:    make(map[int]int),
+		rebased:      make(map[int]bool),
+		dirty:        make(map[int]bool),
+		intRegions:   make(map[int][]int),
+		intInitial:   make(map[int][]int),
+		names:        make(map[int]string),
+		changeCounts: make(map[int]int),
+		hashes:       make(map[int]uint64),
+		severities:   make(map[int]Severity),
+		ranges:       make(map[int][][2]int),
+		fields:       make(map[int][]structField),
+		elemSizes:    make(map[int]int),
+		ignored:      make(map[int]map[int]bool),
+		segments:     make(map[int][][]byte),
+		placements:   make(map[int][][2]int),
+		tags:         make(map[int]map[string]string),
+		limits:       make(map[int]*tokenBucket),
+		throttled:    make(map[int]int),
+		heat:         make(map[int]map[int]int),
+		comparators:  make(map[int]func(old, new []byte) []Diff),
+		granularity:  make(map[int]int),
+		snapshots:    make(map[int][]byte),
+		intSnapshots: make(map[int][]int),
+		now:          time.Now,
+		logf:         discardLog,
+		events:       make([]MemoryEvent, 0),
+		regionCount:  0,
+	}
+}
+
+// nextID allocates a region id. Ids start at 1, so 0 never names a region
+// and can be returned for failures, as in the cgo binding.
+func (mt *MemoryTracker) nextID() int {
+	mt.regionCount++
+	return mt.regionCount
+}
+
+func (mt *MemoryTracker) Watch(data []byte, name string) int {
+	id := mt.nextID()
+	
+	// Clone the data
+	dataCopy := make([]byte, len(data))
+	copy(dataCopy, data)
+	
+	mt.regions[id] = dataCopy
+	mt.setBaselines(id, data)
+	mt.names[id] = name
+	
+	mt.logf("  ✓ Watching region %d: %s\n", id, name)
+	mt.checkSizeBudget()
+	return id
+}
+
+// setBaselines copies data as both the rolling baseline of region id and
+// the untouched original RedundantWrites and OnRestore compare against
+func (mt *MemoryTracker) setBaselines(id int, data []byte) {
+	mt.initial[id] = append([]byte(nil), data...)
+	mt.original[id] = append([]byte(nil), data...)
+}
+
+// WatchSlice watches data[offset:offset+length] in place, e.g. the header
+// of a large buffer. Unlike Watch it copies only that window, for the
+// baseline, and sees writes made through data itself. Event offsets are
+// relative to the window. A window outside data, or an empty one, is not
+// watched and 0 is returned.
+func (mt *MemoryTracker) WatchSlice(data []byte, offset, length int, name string) int {
+	if offset < 0 || length <= 0 || offset > len(data)-length {
+		return 0
+	}
+	
+	window := data[offset : offset+length : offset+length]
+	id := mt.nextID()
+	
+	mt.regions[id] = window
+	mt.setBaselines(id, window)
+	mt.names[id] = name
+	
+	mt.logf("  ✓ Watching region %d: %s\n", id, name)
+	mt.checkSizeBudget()
+	return id
+}
+
+// WatchRange watches data but only reports changes whose offset falls in
+// one of the [start,end) ranges. With no ranges the whole buffer is watched.
+// Ranges must lie within data and must not overlap; otherwise nothing is
+// watched and 0 is returned.
+func (mt *MemoryTracker) WatchRange(data []byte, name string, ranges ...[2]int) int {
+	sorted := append([][2]int(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+	
+	for i, r := range sorted {
+		if r[0] < 0 || r[1] > len(data) || r[0] >= r[1] {
+			return 0
+		}
+		if i > 0 && sorted[i-1][1] > r[0] {
+			return 0
+		}
+	}
+	
+	id := mt.Watch(data, name)
+	if len(sorted) > 0 {
+		mt.ranges[id] = sorted
+	}
+	return id
+}
+
+// watchesOffset reports whether changes at offset are reported for region id
+func (mt *MemoryTracker) watchesOffset(id, offset int) bool {
+	ranges, ok := mt.ranges[id]
+	if !ok {
+		return true
+	}
+	for _, r := range ranges {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignore suppresses events at the given offsets of region id, or for the
+// whole region when no offsets are given. Ignored changes still update the
+// baseline, so they do not fire once the offset is no longer ignored.
+func (mt *MemoryTracker) Ignore(id int, offsets ...int) {
+	if len(offsets) == 0 {
+		// A nil set ignores every offset
+		mt.ignored[id] = nil
+		return
+	}
+	
+	set, ok := mt.ignored[id]
+	if ok && set == nil {
+		return
+	}
+	if set == nil {
+		set = make(map[int]bool, len(offsets))
+		mt.ignored[id] = set
+	}
+	for _, offset := range offsets {
+		set[offset] = true
+	}
+}
+
+// isIgnored reports whether changes at offset of region id are suppressed
+func (mt *MemoryTracker) isIgnored(id, offset int) bool {
+	set, ok := mt.ignored[id]
+	return ok && (set == nil || set[offset])
+}
+
+// WatchMulti watches several buffers as one logical region, laid out one
+// after the other in argument order. The parts are watched live, so later
+// writes to them are seen, and event offsets are relative to the start of
+// the first part.
+func (mt *MemoryTracker) WatchMulti(name string, parts ...[]byte) int {
+	var logical []byte
+	for _, part := range parts {
+		logical = append(logical, part...)
+	}
+	
+	id := mt.nextID()
+	
+	mt.regions[id] = logical
+	mt.setBaselines(id, logical)
+	mt.names[id] = name
+	mt.segments[id] = parts
+	
+	mt.logf("  ✓ Watching region %d: %s\n", id, name)
+	mt.checkSizeBudget()
+	return id
+}
+
+// WatchMultiAt is WatchMulti with each part placed at its own offset of
+// the logical region, so parts may overlap, e.g. two views of one record.
+// Event offsets, Ignore and WatchRange-style filtering use those logical
+// offsets. A byte covered by several parts is compared once per part, so
+// changes there can yield several events for one offset in a pass;
+// SetDedupWithinPass merges them. Granularity word indexes still count
+// through the parts laid end to end. offsets must match parts one to one
+// and be non-negative; otherwise nothing is watched and 0 is returned.
+func (mt *MemoryTracker) WatchMultiAt(name string, offsets []int, parts ...[]byte) int {
+	if len(offsets) != len(parts) {
+		return 0
+	}
+	placements := make([][2]int, len(parts))
+	for i, offset := range offsets {
+		if offset < 0 {
+			return 0
+		}
+		placements[i] = [2]int{offset, len(parts[i])}
+	}
+	
+	id := mt.WatchMulti(name, parts...)
+	mt.placements[id] = placements
+	return id
+}
+
+// logicalOffset maps offset i of region id's parts laid end to end to its
+// WatchMultiAt offset. Other regions keep i.
+func (mt *MemoryTracker) logicalOffset(id, i int) int {
+	placements, ok := mt.placements[id]
+	if !ok {
+		return i
+	}
+	for _, p := range placements {
+		if i < p[1] {
+			return p[0] + i
+		}
+		i -= p[1]
+	}
+	return i
+}
+
+// gatherSegments refreshes the logical buffer of a WatchMulti region from
+// its parts
+func (mt *MemoryTracker) gatherSegments(id int) {
+	region := mt.regions[id][:0]
+	for _, part := range mt.segments[id] {
+		region = append(region, part...)
+	}
+	mt.regions[id] = region
+}
+
+// Unwatch stops watching region id, dropping all of its state. It reports
+// whether the region was watched; id 0 never is.
+func (mt *MemoryTracker) Unwatch(id int) bool {
+	if id == 0 {
+		return false
+	}
+	
+	_, isBytes := mt.regions[id]
+	_, isInts := mt.intRegions[id]
+	if !isBytes && !isInts {
+		return false
+	}
+	
+	delete(mt.regions, id)
+	delete(mt.initial, id)
+	delete(mt.original, id)
+	delete(mt.redundant, id)
+	delete(mt.rebased, id)
+	delete(mt.dirty, id)
+	delete(mt.intRegions, id)
+	delete(mt.intInitial, id)
+	delete(mt.names, id)
+	delete(mt.changeCounts, id)
+	delete(mt.hashes, id)
+	delete(mt.severities, id)
+	delete(mt.ranges, id)
+	delete(mt.fields, id)
+	delete(mt.elemSizes, id)
+	delete(mt.ignored, id)
+	delete(mt.segments, id)
+	delete(mt.placements, id)
+	delete(mt.tags, id)
+	delete(mt.limits, id)
+	delete(mt.throttled, id)
+	delete(mt.heat, id)
+	delete(mt.comparators, id)
+	delete(mt.granularity, id)
+	delete(mt.snapshots, id)
+	delete(mt.intSnapshots, id)
+	mt.checkSizeBudget()
+	return true
+}
+
+// WatchStruct watches the memory of the struct ptr points to, so changes to
+// the struct are seen without re-registering it. Events are named
+// name.Field after the field owning the changed byte. Structs whose fields
+// contain pointers, slices, maps, strings or interfaces are rejected.
+func (mt *MemoryTracker) WatchStruct(ptr interface{}, name string) (int, error) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return 0, fmt.Errorf("WatchStruct needs a non-nil pointer, got %T", ptr)
+	}
+	
+	typ := v.Elem().Type()
+	if typ.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("WatchStruct needs a pointer to a struct, got %T", ptr)
+	}
+	
+	fields := make([]structField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if hasPointers(f.Type) {
+			return 0, fmt.Errorf("field %s.%s of type %s holds pointers", typ.Name(), f.Name, f.Type)
+		}
+		fields = append(fields, structField{name: "." + f.Name, offset: int(f.Offset), size: int(f.Type.Size())})
+	}
+	
+	if typ.Size() == 0 {
+		return 0, errors.New("WatchStruct cannot watch a zero-size struct")
+	}
+	
+	live := unsafe.Slice((*byte)(v.UnsafePointer()), typ.Size())
+	
+	id := mt.nextID()
+	
+	mt.regions[id] = live
+	mt.setBaselines(id, live)
+	mt.names[id] = name
+	mt.fields[id] = fields
+	
+	mt.logf("  ✓ Watching region %d: %s\n", id, name)
+	mt.checkSizeBudget()
+	return id, nil
+}
+
+// hasPointers reports whether values of t contain any Go pointers
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return hasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.String, reflect.Interface,
+		reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return true
+	default:
+		return false
+	}
+}
+
+// WatchSliceOf watches the memory backing s, so element writes are seen
+// without re-registering it. Events are named name[i].Field after the
+// element and field owning the changed byte, or name[i] when T is not a
+// struct. Element types holding pointers, slices, maps, strings or
+// interfaces are rejected, as are empty slices.
+func WatchSliceOf[T any](mt *MemoryTracker, s []T, name string) (int, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if hasPointers(typ) {
+		return 0, fmt.Errorf("element type %s holds pointers", typ)
+	}
+	if len(s) == 0 || typ.Size() == 0 {
+		return 0, fmt.Errorf("WatchSliceOf cannot watch an empty []%s", typ)
+	}
+	
+	fields := make([]structField, 0)
+	if typ.Kind() == reflect.Struct {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			fields = append(fields, structField{name: "." + f.Name, offset: int(f.Offset), size: int(f.Type.Size())})
+		}
+	}
+	
+	size := int(typ.Size())
+	live := unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*size)
+	
+	id := mt.nextID()
+	
+	mt.regions[id] = live
+	mt.setBaselines(id, live)
+	mt.names[id] = name
+	mt.fields[id] = fields
+	mt.elemSizes[id] = size
+	
+	mt.logf("  ✓ Watching region %d: %s\n", id, name)
+	mt.checkSizeBudget()
+	return id, nil
+}
+
+// eventName names the owner of offset in region id: the element and field
+// for regions registered with WatchSliceOf, the struct field for
+// WatchStruct, otherwise the region itself
+func (mt *MemoryTracker) eventName(id, offset int) string {
+	name := mt.names[id]
+	if size, ok := mt.elemSizes[id]; ok {
+		name = fmt.Sprintf("%s[%d]", name, offset/size)
+		offset %= size
+	}
+	for _, f := range mt.fields[id] {
+		if offset >= f.offset && offset < f.offset+f.size {
+			return name + f.name
+		}
+	}
+	if _, ok := mt.fields[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("region_%d", id)
+}
+
+// WatchInts watches an int slice element by element. Events for the region
+// report the element index as Offset and whole int values.
+func (mt *MemoryTracker) WatchInts(data []int, name string) int {
+	id := mt.nextID()
+	
+	dataCopy := make([]int, len(data))
+	copy(dataCopy, data)
+	
+	initialCopy := make([]int, len(data))
+	copy(initialCopy, data)
+	
+	mt.intRegions[id] = dataCopy
+	mt.intInitial[id] = initialCopy
+	mt.names[id] = name
+	
+	mt.logf("  ✓ Watching region %d: %s\n", id, name)
+	mt.checkSizeBudget()
+	return id
+}
+
+// SetSizeBudget calls cb with the total watched byte count whenever
+// registering a region pushes that total above bytes, e.g. to catch a leak
+// of ever more watched buffers. It fires once per crossing: Unwatch has to
+// bring the total back within budget before it fires again. WatchInts
+// regions count 8 bytes per element. bytes <= 0 or a nil cb disables it.
+func (mt *MemoryTracker) SetSizeBudget(bytes int, cb func(total int)) {
+	mt.budget = bytes
+	mt.budgetCb = cb
+	mt.overBudget = false
+}
+
+// checkSizeBudget runs the SetSizeBudget callback if the watched byte
+// count has just crossed the budget, and re-arms it once back within
+func (mt *MemoryTracker) checkSizeBudget() {
+	if mt.budgetCb == nil || mt.budget <= 0 {
+		return
+	}
+	
+	total := mt.watchedBytes()
+	if total <= mt.budget {
+		mt.overBudget = false
+		return
+	}
+	if !mt.overBudget {
+		mt.overBudget = true
+		mt.budgetCb(total)
+	}
+}
+
+// watchedBytes returns the combined size of all watched regions
+func (mt *MemoryTracker) watchedBytes() int {
+	total := 0
+	for _, region := range mt.regions {
+		total += len(region)
+	}
+	for _, values := range mt.intRegions {
+		total += len(values) * int(unsafe.Sizeof(int(0)))
+	}
+	return total
+}
+
+// SetHashDetection makes DetectChanges compare a per-region xxHash of the
+// baseline before falling back to a byte-level diff, which avoids the
+// element-wise comparison for regions that rarely change.
+func (mt *MemoryTracker) SetHashDetection(enabled bool) {
+	mt.hashDetection = enabled
+	if !enabled {
+		mt.hashes = make(map[int]uint64)
+	}
+}
+
+// SetHasher sets the hash used by Checksum and by hash detection, e.g. a
+// cryptographic one for tamper evidence. Without one, or after
+// SetHasher(nil), Checksum uses FNV-1a and hash detection xxHash. Changing
+// the hasher discards the hash-detection baselines.
+func (mt *MemoryTracker) SetHasher(newHash func() hash.Hash64) {
+	mt.hasher = newHash
+	mt.hashes = make(map[int]uint64)
+}
+
+// sum hashes region contents for hash-based change detection
+func (mt *MemoryTracker) sum(data []byte) uint64 {
+	if mt.hasher == nil {
+		return xxhash.Sum64(data)
+	}
+	h := mt.hasher()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// DetectChanges compares every region against its baseline. Regions are
+// visited in ascending id order, so events are grouped by region id and
+// ordered by offset within each region.
+func (mt *MemoryTracker) DetectChanges() {
+	start := len(mt.events)
+	mt.detectedAt = mt.now()
+	mt.passEvents = nil
+	mt.stack = nil
+	if mt.captureStack {
+		pcs := make([]uintptr, 32)
+		mt.stack = pcs[:runtime.Callers(2, pcs)]
+	}
+	for _, id := range mt.regionIDs() {
+		if region, ok := mt.capture(id); ok {
+			mt.detectBytes(id, region)
+			delete(mt.rebased, id)
+			mt.checkRestored(id, region)
+		} else {
+			mt.detectInts(id, mt.captureInts(id))
+		}
+	}
+	mt.publish(mt.events[start:])
+}
+
+// SetConsistentSnapshot makes DetectChanges copy each region's bytes,
+// while holding RegionLock, and compare that copy instead of the live
+// memory. A region another goroutine keeps writing to then yields events
+// and a baseline from a single moment, with no torn reads between them.
+// The copies are kept between calls, so this costs one extra buffer the
+// size of each watched region. It is off by default.
+func (mt *MemoryTracker) SetConsistentSnapshot(enabled bool) {
+	mt.consistent = enabled
+	if !enabled {
+		mt.snapshots = make(map[int][]byte)
+		mt.intSnapshots = make(map[int][]int)
+	}
+}
+
+// RegionLock returns the lock SetConsistentSnapshot copies regions under.
+// Goroutines writing to watched memory while DetectChanges runs hold it
+// for each write they need seen whole.
+func (mt *MemoryTracker) RegionLock() sync.Locker {
+	return &mt.regionMu
+}
+
+// capture returns the bytes of region id to compare on this pass, and
+// false for an int region. WatchMulti regions are gathered from their
+// parts first.
+func (mt *MemoryTracker) capture(id int) ([]byte, bool) {
+	if mt.consistent {
+		mt.regionMu.Lock()
+		defer mt.regionMu.Unlock()
+	}
+	
+	if _, ok := mt.segments[id]; ok {
+		// Gathering already copies the parts into a buffer of our own
+		mt.gatherSegments(id)
+		return mt.regions[id], true
+	}
+	region, ok := mt.regions[id]
+	if !ok || !mt.consistent {
+		return region, ok
+	}
+	mt.snapshots[id] = append(mt.snapshots[id][:0], region...)
+	return mt.snapshots[id], true
+}
+
+// captureInts is capture for int regions
+func (mt *MemoryTracker) captureInts(id int) []int {
+	values := mt.intRegions[id]
+	if !mt.consistent {
+		return values
+	}
+	
+	mt.regionMu.Lock()
+	defer mt.regionMu.Unlock()
+	mt.intSnapshots[id] = append(mt.intSnapshots[id][:0], values...)
+	return mt.intSnapshots[id]
+}
+
+// Checksum folds the current contents of every watched region, in
+// ascending id order, into one FNV-1a hash, or the SetHasher hash.
+// Identical states give the same checksum, so comparing checksums detects
+// any change without a diff.
+func (mt *MemoryTracker) Checksum() uint64 {
+	var h hash.Hash64
+	if mt.hasher != nil {
+		h = mt.hasher()
+	} else {
+		h = fnv.New64a()
+	}
+	var word [8]byte
+	
+	for _, id := range mt.regionIDs() {
+		binary.LittleEndian.PutUint64(word[:], uint64(id))
+		h.Write(word[:])
+		
+		if parts, ok := mt.segments[id]; ok {
+			for _, part := range parts {
+				h.Write(part)
+			}
+		} else if region, ok := mt.regions[id]; ok {
+			h.Write(region)
+		} else {
+			for _, v := range mt.intRegions[id] {
+				binary.LittleEndian.PutUint64(word[:], uint64(v))
+				h.Write(word[:])
+			}
+		}
+	}
+	
+	return h.Sum64()
+}
+
+// regionIDs returns the ids of all watched regions in ascending order
+func (mt *MemoryTracker) regionIDs() []int {
+	ids := make([]int, 0, len(mt.regions)+len(mt.intRegions))
+	for id := range mt.regions {
+		ids = append(ids, id)
+	}
+	for id := range mt.intRegions {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (mt *MemoryTracker) detectBytes(id int, region []byte) {
+	init := mt.initial[id]
+	if len(init) != len(region) {
+		mt.resized(id, len(init), len(region))
+		mt.initial[id] = append([]byte(nil), region...)
+		mt.original[id] = append([]byte(nil), region...)
+		if mt.hashDetection {
+			mt.hashes[id] = mt.sum(region)
+		}
+		return
+	}
+	
+	if mt.hashDetection {
+		baseline, ok := mt.hashes[id]
+		if !ok {
+			baseline = mt.sum(init)
+		}
+		current := mt.sum(region)
+		mt.hashes[id] = current
+		if current == baseline {
+			return
+		}
+	}
+	
+	if cmp, ok := mt.comparators[id]; ok {
+		if mt.detectWith(id, cmp, init, region) {
+			mt.scanSignatures(id, region)
+		}
+		return
+	}
+	
+	if size := mt.granularity[id]; size > 1 {
+		if mt.detectWords(id, size, init, region) {
+			mt.scanSignatures(id, region)
+		}
+		copy(init, region)
+		return
+	}
+	
+	// The baseline is a copy of the region, so the lengths always match
+	events, _ := DiffBytes(init, region, "")
+	for _, evt := range events {
+		mt.recordByte(id, evt.Offset, byte(evt.OldValue), byte(evt.NewValue))
+	}
+	copy(init, region)
+	
+	if len(events) > 0 {
+		mt.scanSignatures(id, region)
+	}
+}
+
+// DiffBytes compares two equal-length buffers and returns one event named
+// name for each differing byte, in offset order. This is the comparison
+// DetectChanges applies to byte regions, usable on snapshots without a
+// tracker. Buffers of different lengths are an error.
+func DiffBytes(old, cur []byte, name string) ([]MemoryEvent, error) {
+	if len(old) != len(cur) {
+		return nil, fmt.Errorf("cannot diff %s: %d bytes against %d", name, len(old), len(cur))
+	}
+	
+	var events []MemoryEvent
+	for i := range old {
+		if old[i] != cur[i] {
+			events = append(events, MemoryEvent{
+				Name:     name,
+				Offset:   i,
+				OldValue: int(old[i]),
+				NewValue: int(cur[i]),
+			})
+		}
+	}
+	return events, nil
+}
+
+// detectWith reports the offsets cmp considers changed. Only those offsets
+// move the baseline, so differences cmp ignores are kept and compared
+// again on the next pass. Reports whether cmp found any change.
+func (mt *MemoryTracker) detectWith(id int, cmp func(old, new []byte) []Diff, init, region []byte) bool {
+	changed := false
+	for _, diff := range cmp(append([]byte(nil), init...), append([]byte(nil), region...)) {
+		i := diff.Offset
+		if i < 0 || i >= len(region) {
+			continue
+		}
+		mt.recordByte(id, i, init[i], region[i])
+		init[i] = region[i]
+		changed = true
+	}
+	return changed
+}
+
+// detectWords records one event per changed size-byte word. A trailing
+// partial word is compared as a shorter word. Reports whether any word
+// changed.
+func (mt *MemoryTracker) detectWords(id, size int, init, region []byte) bool {
+	changed := false
+	for start := 0; start < len(region); start += size {
+		end := start + size
+		if end > len(region) {
+			end = len(region)
+		}
+		if bytes.Equal(init[start:end], region[start:end]) {
+			continue
+		}
+		changed = true
+		if !mt.watchesOffset(id, start) || mt.isIgnored(id, start) {
+			continue
+		}
+		if orig := mt.original[id]; !mt.rebased[id] && end <= len(orig) && bytes.Equal(region[start:end], orig[start:end]) {
+			mt.redundant[id]++
+			continue
+		}
+		mt.record(id, MemoryEvent{
+			Name:     mt.eventName(id, start),
+			Offset:   start / size,
+			OldValue: wordValue(init[start:end]),
+			NewValue: wordValue(region[start:end]),
+		})
+	}
+	return changed
+}
+
+// wordValue reads up to 8 bytes as a little-endian integer
+func wordValue(word []byte) int {
+	var buf [8]byte
+	copy(buf[:], word)
+	return int(binary.LittleEndian.Uint64(buf[:]))
+}
+
+// recordByte records a change of the byte at offset i, unless the offset
+// is outside the watched ranges or ignored. A write of the value the byte
+// had at the original baseline is counted as redundant instead.
+func (mt *MemoryTracker) recordByte(id, i int, old, cur byte) {
+	offset := mt.logicalOffset(id, i)
+	if !mt.watchesOffset(id, offset) || mt.isIgnored(id, offset) {
+		return
+	}
+	if orig := mt.original[id]; !mt.rebased[id] && i < len(orig) && cur == orig[i] {
+		mt.redundant[id]++
+		return
+	}
+	mt.record(id, MemoryEvent{
+		Name:     mt.eventName(id, offset),
+		Offset:   offset,
+		OldValue: int(old),
+		NewValue: int(cur),
+	})
+}
+
+func (mt *MemoryTracker) detectInts(id int, values []int) {
+	init := mt.intInitial[id]
+	if len(init) != len(values) {
+		mt.resized(id, len(init), len(values))
+		mt.intInitial[id] = append([]int(nil), values...)
+		return
+	}
+	
+	for i := 0; i < len(values); i++ {
+		if init[i] != values[i] {
+			if !mt.isIgnored(id, i) {
+				mt.record(id, MemoryEvent{
+					Name:     fmt.Sprintf("region_%d", id),
+					Offset:   i,
+					OldValue: init[i],
+					NewValue: values[i],
+				})
+			}
+			init[i] = values[i]
+		}
+	}
+}
+
+// resized records that region id changed length. Its contents are not
+// compared on that pass, as offsets no longer line up with the baseline.
+func (mt *MemoryTracker) resized(id, oldLen, newLen int) {
+	mt.resizes = append(mt.resizes, ResizeEvent{RegionID: id, OldLen: oldLen, NewLen: newLen})
+}
+
+// ResizeEvents returns the region length changes seen by DetectChanges, in
+// detection order
+func (mt *MemoryTracker) ResizeEvents() []ResizeEvent {
+	return append([]ResizeEvent(nil), mt.resizes...)
+}
+
+// record appends an event detected in region id, unless the region's rate
+// limit drops it
+func (mt *MemoryTracker) record(id int, evt MemoryEvent) {
+	key := [2]int{id, evt.Offset}
+	if i, ok := mt.passEvents[key]; ok {
+		mt.events[i].NewValue = evt.NewValue
+		return
+	}
+	
+	if bucket, ok := mt.limits[id]; ok && !bucket.take(mt.detectedAt) {
+		mt.throttled[id]++
+		return
+	}
+	
+	evt.Severity = mt.severities[id]
+	evt.DetectedAt = mt.detectedAt
+	evt.Tags = copyTags(mt.tags[id])
+	evt.Stack = mt.stack
+	mt.events = append(mt.events, evt)
+	mt.changeCounts[id]++
+	
+	if mt.heat[id] == nil {
+		mt.heat[id] = make(map[int]int)
+	}
+	mt.heat[id][evt.Offset]++
+	
+	if mt.dedupPass {
+		if mt.passEvents == nil {
+			mt.passEvents = make(map[[2]int]int)
+		}
+		mt.passEvents[key] = len(mt.events) - 1
+	}
+}
+
+// AddSignature registers a known-bad byte pattern, such as a canary
+// overwrite. Whenever DetectChanges finds a byte region changed, it scans
+// the whole region and records a SignatureEvent for every match. A 0xFF
+// byte in pattern is a wildcard matching any value. Empty patterns are
+// ignored.
+func (mt *MemoryTracker) AddSignature(name string, pattern []byte) {
+	if len(pattern) == 0 {
+		return
+	}
+	
+	mask := make([]bool, len(pattern))
+	for i, b := range pattern {
+		mask[i] = b != 0xFF
+	}
+	mt.signatures = append(mt.signatures, signature{
+		name:    name,
+		pattern: append([]byte(nil), pattern...),
+		mask:    mask,
+	})
+}
+
+// SignatureEvents returns the signature matches recorded so far, in
+// detection order
+func (mt *MemoryTracker) SignatureEvents() []SignatureEvent {
+	return append([]SignatureEvent(nil), mt.sigEvents...)
+}
+
+// scanSignatures records a SignatureEvent for every signature match in
+// region id
+func (mt *MemoryTracker) scanSignatures(id int, region []byte) {
+	for _, sig := range mt.signatures {
+		for offset := 0; offset+len(sig.pattern) <= len(region); offset++ {
+			if sig.matches(region[offset:]) {
+				mt.sigEvents = append(mt.sigEvents, SignatureEvent{Name: sig.name, RegionID: id, Offset: offset})
+			}
+		}
+	}
+}
+
+// matches reports whether data starts with the signature
+func (sig signature) matches(data []byte) bool {
+	for i, b := range sig.pattern {
+		if sig.mask[i] && data[i] != b {
+			return false
+		}
+	}
+	return true
+}
+
+// SetBaseline replaces the copy region id is diffed against with
+// baseline, e.g. a known-good snapshot taken earlier, so the next
+// DetectChanges reports how the region differs from it, including bytes
+// at their original value, which that pass does not count as
+// RedundantWrites. The original OnRestore compares against stays the
+// Watch-time contents. baseline is copied and must match the region's
+// length. Only byte regions have a byte baseline; WatchInts regions are an
+// error.
+func (mt *MemoryTracker) SetBaseline(id int, baseline []byte) error {
+	init, ok := mt.initial[id]
+	if !ok {
+		return fmt.Errorf("no byte region with id %d", id)
+	}
+	if len(baseline) != len(init) {
+		return fmt.Errorf("baseline for %s has %d bytes, region has %d", mt.names[id], len(baseline), len(init))
+	}
+	
+	copy(init, baseline)
+	delete(mt.hashes, id)
+	mt.rebased[id] = true
+	return nil
+}
+
+// SetGranularity makes DetectChanges compare byte region id in words of
+// size bytes, 1, 2, 4 or 8, and emit one event per changed word. Such
+// events have the word index as Offset and the little-endian word values
+// as OldValue and NewValue. Watched ranges, ignored offsets and event
+// names apply to the first byte of each word. Other sizes are ignored; 1
+// restores the default byte granularity.
+func (mt *MemoryTracker) SetGranularity(id int, bytes int) {
+	switch bytes {
+	case 1:
+		delete(mt.granularity, id)
+	case 2, 4, 8:
+		mt.granularity[id] = bytes
+	}
+}
+
+// SetComparator replaces byte-exact comparison for region id with cmp,
+// which receives copies of the baseline and the current bytes and returns
+// the offsets it considers changed. Use it for fields where some
+// differences do not matter, such as float noise below an epsilon. It
+// applies to byte regions, not WatchInts ones; a nil cmp restores the
+// default.
+func (mt *MemoryTracker) SetComparator(id int, cmp func(old, new []byte) []Diff) {
+	if cmp == nil {
+		delete(mt.comparators, id)
+		return
+	}
+	mt.comparators[id] = cmp
+}
+
+// SetCaptureStack records the stack of the goroutine calling DetectChanges
+// in the Stack of each event it produces, resolvable with StackFrames.
+// Capturing costs a stack walk per DetectChanges call, so it is off by
+// default. Events of one call share the same Stack slice.
+func (mt *MemoryTracker) SetCaptureStack(enabled bool) {
+	mt.captureStack = enabled
+}
+
+// SetDedupWithinPass makes DetectChanges emit at most one event per region
+// and offset in a single call, as overlapping WatchMultiAt parts can
+// otherwise produce. A repeated change updates the NewValue of the event
+// already emitted instead of adding another.
+func (mt *MemoryTracker) SetDedupWithinPass(enabled bool) {
+	mt.dedupPass = enabled
+	mt.passEvents = nil
+}
+
+// SetLogger routes the tracker's progress messages, such as the one printed
+// when a region is watched, to logf. The default discards them; log.Printf
+// or a wrapper around fmt.Printf brings them back. A nil logf discards again.
+func (mt *MemoryTracker) SetLogger(logf func(format string, args ...interface{})) {
+	if logf == nil {
+		logf = discardLog
+	}
+	mt.logf = logf
+}
+
+// discardLog is the default logger
+func discardLog(format string, args ...interface{}) {}
+
+// SetClock replaces the clock stamping DetectedAt, so tests can use a fixed
+// time. A nil now restores time.Now.
+func (mt *MemoryTracker) SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	mt.now = now
+}
+
+// SetRateLimit caps region id at maxPerSec events per second, measured
+// with the tracker's clock. Changes over the limit still update the
+// baseline but produce no event; they are counted in RegionInfo.Throttled.
+// A maxPerSec of 0 or less removes the limit.
+func (mt *MemoryTracker) SetRateLimit(id int, maxPerSec int) {
+	if maxPerSec <= 0 {
+		delete(mt.limits, id)
+		return
+	}
+	
+	rate := float64(maxPerSec)
+	mt.limits[id] = &tokenBucket{rate: rate, tokens: rate, last: mt.now()}
+}
+
+// SetRegionTags attaches tags to every event region id produces from now
+// on, replacing any earlier tags. The map is copied, as is each event's
+// Tags, so neither later edits to tags nor to an event affect the others.
+func (mt *MemoryTracker) SetRegionTags(id int, tags map[string]string) {
+	if len(tags) == 0 {
+		delete(mt.tags, id)
+		return
+	}
+	mt.tags[id] = copyTags(tags)
+}
+
+// copyTags returns an independent copy of tags, or nil when there are none
+func copyTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
+
+// DroppedByRegion returns how many events each region lost, keyed by
+// region id. Regions that dropped nothing are omitted. Drops currently
+// come from SetRateLimit throttling.
+func (mt *MemoryTracker) DroppedByRegion() map[int]uint64 {
+	dropped := make(map[int]uint64, len(mt.throttled))
+	for id, n := range mt.throttled {
+		if n > 0 {
+			dropped[id] += uint64(n)
+		}
+	}
+	return dropped
+}
+
+// subscribeBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it
+const subscribeBuffer = 64
+
+// Subscribe returns a channel receiving every event DetectChanges records
+// from now on, and a function ending the subscription. Delivery never
+// blocks DetectChanges: events that do not fit in a slow subscriber's
+// buffer are dropped for it alone and counted in DroppedBySubscriber. The
+// unsubscribe function closes the channel and may be called more than
+// once, from any goroutine.
+func (mt *MemoryTracker) Subscribe() (<-chan MemoryEvent, func()) {
+	sub := &subscriber{ch: make(chan MemoryEvent, subscribeBuffer)}
+	
+	mt.subMu.Lock()
+	mt.subs = append(mt.subs, sub)
+	mt.subMu.Unlock()
+	
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			mt.subMu.Lock()
+			defer mt.subMu.Unlock()
+			for i, s := range mt.subs {
+				if s == sub {
+					mt.subs = append(mt.subs[:i], mt.subs[i+1:]...)
+					break
+				}
+			}
+			close(sub.ch)
+		})
+	}
+}
+
+// publish hands events to every subscriber without blocking
+func (mt *MemoryTracker) publish(events []MemoryEvent) {
+	if len(events) == 0 {
+		return
+	}
+	
+	mt.subMu.Lock()
+	defer mt.subMu.Unlock()
+	for _, sub := range mt.subs {
+		for _, evt := range events {
+			select {
+			case sub.ch <- evt:
+			default:
+				sub.dropped++
+			}
+		}
+	}
+}
+
+// DroppedBySubscriber returns how many events each active subscription
+// missed because its channel was full, keyed by the channel Subscribe
+// returned. Subscriptions that dropped nothing are omitted.
+func (mt *MemoryTracker) DroppedBySubscriber() map[<-chan MemoryEvent]uint64 {
+	mt.subMu.Lock()
+	defer mt.subMu.Unlock()
+	
+	dropped := make(map[<-chan MemoryEvent]uint64)
+	for _, sub := range mt.subs {
+		if sub.dropped > 0 {
+			dropped[sub.ch] = sub.dropped
+		}
+	}
+	return dropped
+}
+
+// OnRestore calls cb from DetectChanges whenever a byte region that
+// differed from its contents at Watch time is byte-identical to them
+// again. SetBaseline does not move that original; a resize does. A nil cb
+// stops the checks.
+func (mt *MemoryTracker) OnRestore(cb func(regionID int)) {
+	mt.restoreCb = cb
+}
+
+// checkRestored tracks whether region id differs from its original and
+// runs the OnRestore callback when it stops differing
+func (mt *MemoryTracker) checkRestored(id int, region []byte) {
+	if mt.restoreCb == nil {
+		return
+	}
+	orig, ok := mt.original[id]
+	if !ok {
+		return
+	}
+	
+	dirty := !bytes.Equal(region, orig)
+	wasDirty := mt.dirty[id]
+	if dirty {
+		mt.dirty[id] = true
+	} else {
+		delete(mt.dirty, id)
+	}
+	if wasDirty && !dirty {
+		mt.restoreCb(id)
+	}
+}
+
+// RedundantWrites returns how many writes DetectChanges found in byte
+// region id that stored the value a byte, or a word under SetGranularity,
+// already had at the original baseline: churn that leaves the region where
+// it started. The comparison is against that untouched original, not the
+// rolling baseline, and a resize starts a new original. Redundant writes
+// are counted instead of recorded as events, so replaying the events does
+// not see them; OnRestore reports a region that is back at its original.
+func (mt *MemoryTracker) RedundantWrites(id int) int {
+	return mt.redundant[id]
+}
+
+// OffsetHeatmap returns how many events have touched each offset of region
+// id over the tracker's lifetime, keyed by offset. Offsets are element
+// indexes for WatchInts regions. The counts are kept apart from the events
+// slice, so they stay accurate even if events are ever capped or cleared.
+func (mt *MemoryTracker) OffsetHeatmap(id int) map[int]int {
+	heatmap := make(map[int]int, len(mt.heat[id]))
+	for offset, n := range mt.heat[id] {
+		heatmap[offset] = n
+	}
+	return heatmap
+}
+
+// SetRegionSeverity sets the severity carried by events from a region.
+// Regions default to Info.
+func (mt *MemoryTracker) SetRegionSeverity(id int, sev Severity) {
+	mt.severities[id] = sev
+}
+
+// RegionInfo returns the metadata of a watched region. Id 0 is never found.
+func (mt *MemoryTracker) RegionInfo(id int) (RegionInfo, bool) {
+	if id == 0 {
+		return RegionInfo{}, false
+	}
+	
+	var size int
+	if region, ok := mt.regions[id]; ok {
+		size = len(region)
+	} else if values, ok := mt.intRegions[id]; ok {
+		size = len(values)
+	} else {
+		return RegionInfo{}, false
+	}
+	
+	return RegionInfo{
+		ID:          id,
+		Name:        mt.names[id],
+		Size:        size,
+		ChangeCount: mt.changeCounts[id],
+		Throttled:   mt.throttled[id],
+	}, true
+}
+
+// MergeTrackers returns the events of all trackers as one slice. Event
+// names are prefixed with "tracker<i>/" after the tracker's position in
+// the argument list, and events are sorted by name and offset. The
+// trackers themselves are left untouched.
+func MergeTrackers(trackers ...*MemoryTracker) []MemoryEvent {
+	var merged []MemoryEvent
+	for i, mt := range trackers {
+		if mt == nil {
+			continue
+		}
+		for _, evt := range mt.events {
+			evt.Name = fmt.Sprintf("tracker%d/%s", i, evt.Name)
+			merged = append(merged, evt)
+		}
+	}
+	
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Name != merged[j].Name {
+			return merged[i].Name < merged[j].Name
+		}
+		return merged[i].Offset < merged[j].Offset
+	})
+	return merged
+}
+
+// Report writes the recorded events to out as an aligned table with one
+// row per event, showing old and new values in hex and decimal, followed by
+// a summary line counting events and distinct regions.
+func (mt *MemoryTracker) Report(out io.Writer) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REGION\tOFFSET\tOLD\tNEW")
+	
+	regions := make(map[string]bool)
+	for _, evt := range mt.events {
+		regions[evt.Name] = true
+		fmt.Fprintf(tw, "%s\t%d\t0x%02x (%d)\t→ 0x%02x (%d)\n", evt.Name, evt.Offset, evt.OldValue, evt.OldValue, evt.NewValue, evt.NewValue)
+	}
+	tw.Flush()
+	
+	fmt.Fprintf(out, "%d events in %d regions\n", len(mt.events), len(regions))
+}
+
+// traceEvent is one entry of the Chrome Trace Event Format
+type traceEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat"`
+	Ph    string                 `json:"ph"`
+	Scope string                 `json:"s"`
+	Ts    float64                `json:"ts"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Args  map[string]interface{} `json:"args"`
+}
+
+// ExportTraceEvents writes the recorded events as a Chrome Trace Event
+// Format JSON array, loadable in chrome://tracing or Perfetto. Each event
+// becomes a thread-scoped instant event categorized by region name and
+// timestamped, in microseconds, with its DetectedAt.
+func (mt *MemoryTracker) ExportTraceEvents(w io.Writer) error {
+	trace := make([]traceEvent, 0, len(mt.events))
+	for _, evt := range mt.events {
+		trace = append(trace, traceEvent{
+			Name:  fmt.Sprintf("%s+%d", evt.Name, evt.Offset),
+			Cat:   evt.Name,
+			Ph:    "i",
+			Scope: "t",
+			Ts:    float64(evt.DetectedAt.UnixNano()) / 1e3,
+			Pid:   1,
+			Tid:   1,
+			Args: map[string]interface{}{
+				"offset":    evt.Offset,
+				"old_value": evt.OldValue,
+				"new_value": evt.NewValue,
+				"severity":  evt.Severity.String(),
+			},
+		})
+	}
+	
+	return json.NewEncoder(w).Encode(trace)
+}
+
+// ReplayEvents applies byte-level events, in order, to a copy of initial
+// and returns the buffer state after each event; it is the inverse of
+// DetectChanges. Events that cannot be applied leave the state unchanged;
+// ReplayEventsWithWarnings reports them.
+func ReplayEvents(initial []byte, events []MemoryEvent) [][]byte {
+	states, _ := ReplayEventsWithWarnings(initial, events)
+	return states
+}
+
+// ReplayEventsWithWarnings is ReplayEvents that also returns one warning
+// per skipped event: an offset outside the buffer or a NewValue that does
+// not fit in a byte.
+func ReplayEventsWithWarnings(initial []byte, events []MemoryEvent) ([][]byte, []string) {
+	states := make([][]byte, 0, len(events))
+	var warnings []string
+	
+	current := append([]byte(nil), initial...)
+	for i, evt := range events {
+		switch {
+		case evt.Offset < 0 || evt.Offset >= len(current):
+			warnings = append(warnings, fmt.Sprintf("event %d: offset %d outside %d-byte buffer", i, evt.Offset, len(current)))
+		case evt.NewValue < 0 || evt.NewValue > 0xFF:
+			warnings = append(warnings, fmt.Sprintf("event %d: value %d is not a byte", i, evt.NewValue))
+		default:
+			current[evt.Offset] = byte(evt.NewValue)
+		}
+		states = append(states, append([]byte(nil), current...))
+	}
+	
+	return states, warnings
+}
+
+func main() {
+	fmt.Println("🧪 Go Memory Tracking Test")
+	fmt.Println("==========================")
+	
+	tracker := NewMemoryTracker()
+	tracker.SetLogger(func(format string, args ...interface{}) {
+		fmt.Printf(format, args...)
+	})
+	data := make([]byte, 20)
+	
+	regionId := tracker.Watch(data, "test_buffer")
+	_ = regionId
+	
+	data[0] = 42
+	data[5] = 99
+	data[10] = 255
+	
+	// Update the tracker with modified data
+	tracker.regions[regionId] = data
+	
+	tracker.DetectChanges()
+	
+	if len(tracker.events) == 3 {
+		fmt.Printf("✅ PASS - Go: Detected %d changes\n", len(tracker.events))
+		tracker.Report(os.Stdout)
+	} else {
+		fmt.Printf("❌ FAIL - Go: Expected 3 changes, got %d\n", len(tracker.events))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AssertChanges runs DetectChanges and checks that the events it produces
+// match want, ignoring order. A want event with a zero DetectedAt matches
+// any detection time, and one without a Stack any stack. On mismatch it
+// fails t with a list of the missing and unexpected events.
+//
+// It is a method, so it must be declared in package main, which cannot be
+// imported; keeping it in a _test.go file keeps the testing package out of
+// the binary.
+func (mt *MemoryTracker) AssertChanges(t testing.TB, want []MemoryEvent) {
+	t.Helper()
+	
+	start := len(mt.events)
+	mt.DetectChanges()
+	got := mt.events[start:]
+	
+	matched := make([]bool, len(want))
+	var extra []MemoryEvent
+	for _, evt := range got {
+		found := false
+		for i, w := range want {
+			if !matched[i] && sameEvent(evt, w) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			extra = append(extra, evt)
+		}
+	}
+	
+	var missing []MemoryEvent
+	for i, w := range want {
+		if !matched[i] {
+			missing = append(missing, w)
+		}
+	}
+	
+	if len(missing) == 0 && len(extra) == 0 {
+		return
+	}
+	
+	var b strings.Builder
+	fmt.Fprintf(&b, "DetectChanges produced %d events, want %d", len(got), len(want))
+	for _, evt := range missing {
+		fmt.Fprintf(&b, "\n  - missing %s", formatEvent(evt))
+	}
+	for _, evt := range extra {
+		fmt.Fprintf(&b, "\n  + extra   %s", formatEvent(evt))
+	}
+	t.Errorf("%s", b.String())
+}
+
+// sameEvent reports whether got matches the expected event want
+func sameEvent(got, want MemoryEvent) bool {
+	if !want.DetectedAt.IsZero() && !got.DetectedAt.Equal(want.DetectedAt) {
+		return false
+	}
+	got.DetectedAt, want.DetectedAt = time.Time{}, time.Time{}
+	if want.Stack == nil {
+		got.Stack = nil
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func formatEvent(evt MemoryEvent) string {
+	return fmt.Sprintf("%s[%d]: %d -> %d (%s)", evt.Name, evt.Offset, evt.OldValue, evt.NewValue, evt.Severity)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// trackerState is the part of a MemoryTracker SaveState persists
+type trackerState struct {
+	Regions      map[int][]byte
+	Initial      map[int][]byte
+	Original     map[int][]byte
+	IntRegions   map[int][]int
+	IntInitial   map[int][]int
+	Names        map[int]string
+	ChangeCounts map[int]int
+	Severities   map[int]Severity
+	Tags         map[int]map[string]string
+	Ranges       map[int][][2]int
+	Ignored      map[int]map[int]bool
+	Fields       map[int][]savedField
+	ElemSizes    map[int]int
+	Placements   map[int][][2]int
+	Events       []MemoryEvent
+	RegionCount  int
+}
+
+// savedField is structField with exported fields, for gob
+type savedField struct {
+	Name   string
+	Offset int
+	Size   int
+}
+
+// SaveState writes the tracker's regions, baselines, names, severities,
+// tags, watched ranges, ignored offsets, field layouts, events and
+// counters to w in gob format, for LoadState to pick up in a later run.
+// Settings such as rate limits, comparators, hooks and subscriptions are
+// not saved.
+func (mt *MemoryTracker) SaveState(w io.Writer) error {
+	fields := make(map[int][]savedField, len(mt.fields))
+	for id, layout := range mt.fields {
+		saved := make([]savedField, 0, len(layout))
+		for _, f := range layout {
+			saved = append(saved, savedField{Name: f.name, Offset: f.offset, Size: f.size})
+		}
+		fields[id] = saved
+	}
+
+	return gob.NewEncoder(w).Encode(trackerState{
+		Regions:      mt.regions,
+		Initial:      mt.initial,
+		Original:     mt.original,
+		IntRegions:   mt.intRegions,
+		IntInitial:   mt.intInitial,
+		Names:        mt.names,
+		ChangeCounts: mt.changeCounts,
+		Severities:   mt.severities,
+		Tags:         mt.tags,
+		Ranges:       mt.ranges,
+		Ignored:      mt.ignored,
+		Fields:       fields,
+		ElemSizes:    mt.elemSizes,
+		Placements:   mt.placements,
+		Events:       mt.events,
+		RegionCount:  mt.regionCount,
+	})
+}
+
+// LoadState returns a tracker with the state SaveState wrote to r. Every
+// region comes back as a byte or int region holding the saved contents,
+// so WatchStruct, WatchSliceOf and WatchMulti regions no longer follow the
+// memory they were watching, though their events keep their field names.
+// New regions get ids after the saved ones.
+func LoadState(r io.Reader) (*MemoryTracker, error) {
+	var state trackerState
+	if err := gob.NewDecoder(r).Decode(&state); err != nil {
+		return nil, err
+	}
+
+	// gob leaves empty maps nil, so fill the fresh tracker's maps instead
+	mt := NewMemoryTracker()
+	for id, region := range state.Regions {
+		mt.regions[id] = region
+	}
+	for id, init := range state.Initial {
+		mt.initial[id] = init
+		if orig, ok := state.Original[id]; ok {
+			mt.original[id] = orig
+		} else {
+			// State saved before originals were kept
+			mt.original[id] = append([]byte(nil), init...)
+		}
+	}
+	for id, values := range state.IntRegions {
+		mt.intRegions[id] = values
+	}
+	for id, init := range state.IntInitial {
+		mt.intInitial[id] = init
+	}
+	for id, name := range state.Names {
+		mt.names[id] = name
+	}
+	for id, n := range state.ChangeCounts {
+		mt.changeCounts[id] = n
+	}
+	for id, sev := range state.Severities {
+		mt.severities[id] = sev
+	}
+	for id, tags := range state.Tags {
+		mt.tags[id] = tags
+	}
+	for id, ranges := range state.Ranges {
+		mt.ranges[id] = ranges
+	}
+	for id, offsets := range state.Ignored {
+		// gob turns the nil set of an Ignore-everything region into an
+		// empty one
+		if len(offsets) == 0 {
+			offsets = nil
+		}
+		mt.ignored[id] = offsets
+	}
+	for id, saved := range state.Fields {
+		// A WatchSliceOf region of scalars has an empty, not a nil, layout
+		layout := make([]structField, 0, len(saved))
+		for _, f := range saved {
+			layout = append(layout, structField{name: f.Name, offset: f.Offset, size: f.Size})
+		}
+		mt.fields[id] = layout
+	}
+	for id, size := range state.ElemSizes {
+		mt.elemSizes[id] = size
+	}
+	for id, placements := range state.Placements {
+		mt.placements[id] = placements
+	}
+	mt.events = append(mt.events, state.Events...)
+	mt.regionCount = state.RegionCount
+
+	return mt, nil
+}
@@ -0,0 +1,1401 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchIntsReportsElementChanges(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.WatchInts([]int{1, 2, 3}, "ints")
+
+	tracker.intRegions[id][1] = 99
+	tracker.DetectChanges()
+
+	if len(tracker.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(tracker.events))
+	}
+	evt := tracker.events[0]
+	if evt.Offset != 1 || evt.OldValue != 2 || evt.NewValue != 99 {
+		t.Errorf("event = %+v, want offset 1, 2 -> 99", evt)
+	}
+}
+
+func TestRegionInfoTracksNameSizeAndChanges(t *testing.T) {
+	tracker := NewMemoryTracker()
+	header := tracker.Watch(make([]byte, 16), "header")
+	payload := tracker.Watch(make([]byte, 64), "payload")
+
+	tracker.regions[payload][3] = 1
+	tracker.regions[payload][9] = 2
+	tracker.DetectChanges()
+
+	tests := []struct {
+		id   int
+		want RegionInfo
+	}{
+		{header, RegionInfo{ID: header, Name: "header", Size: 16, ChangeCount: 0}},
+		{payload, RegionInfo{ID: payload, Name: "payload", Size: 64, ChangeCount: 2}},
+	}
+	for _, tt := range tests {
+		info, ok := tracker.RegionInfo(tt.id)
+		if !ok {
+			t.Fatalf("RegionInfo(%d) not found", tt.id)
+		}
+		if info != tt.want {
+			t.Errorf("RegionInfo(%d) = %+v, want %+v", tt.id, info, tt.want)
+		}
+	}
+
+	if _, ok := tracker.RegionInfo(99); ok {
+		t.Error("RegionInfo(99) found an unknown region")
+	}
+}
+
+func TestHashDetectionMatchesFullScan(t *testing.T) {
+	run := func(hashed bool) []MemoryEvent {
+		tracker := NewMemoryTracker()
+		tracker.SetHashDetection(hashed)
+		tracker.SetClock(func() time.Time { return time.Unix(0, 0) })
+		a := tracker.Watch(make([]byte, 32), "a")
+		tracker.Watch(make([]byte, 32), "b")
+
+		tracker.DetectChanges()
+		tracker.regions[a][4] = 7
+		tracker.regions[a][20] = 9
+		tracker.DetectChanges()
+		tracker.regions[a][4] = 8
+		tracker.DetectChanges()
+		return tracker.events
+	}
+
+	full, hashed := run(false), run(true)
+	if len(full) != 3 || len(hashed) != len(full) {
+		t.Fatalf("full scan produced %d events, hash mode %d, want 3 each", len(full), len(hashed))
+	}
+	for i := range full {
+		if !reflect.DeepEqual(full[i], hashed[i]) {
+			t.Errorf("event %d: full scan %+v, hash mode %+v", i, full[i], hashed[i])
+		}
+	}
+}
+
+func benchmarkUnchangedRegions(b *testing.B, hashed bool) {
+	tracker := NewMemoryTracker()
+	tracker.SetHashDetection(hashed)
+	for i := 0; i < 100; i++ {
+		tracker.Watch(make([]byte, 1<<20), "region")
+	}
+	tracker.DetectChanges()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tracker.DetectChanges()
+	}
+}
+
+func BenchmarkDetectChangesFullScan(b *testing.B) { benchmarkUnchangedRegions(b, false) }
+
+func BenchmarkDetectChangesHash(b *testing.B) { benchmarkUnchangedRegions(b, true) }
+
+func TestRegionSeverityIsCarriedByEvents(t *testing.T) {
+	tracker := NewMemoryTracker()
+	plain := tracker.Watch(make([]byte, 4), "plain")
+	secret := tracker.Watch(make([]byte, 4), "secret")
+	tracker.SetRegionSeverity(secret, Critical)
+
+	tracker.regions[secret][0] = 1
+	tracker.regions[secret][2] = 1
+	tracker.regions[plain][1] = 1
+	tracker.DetectChanges()
+
+	if len(tracker.events) != 3 {
+		t.Fatalf("got %d events, want 3", len(tracker.events))
+	}
+	for _, evt := range tracker.events {
+		want := Info
+		if evt.Name == fmt.Sprintf("region_%d", secret) {
+			want = Critical
+		}
+		if evt.Severity != want {
+			t.Errorf("%s[%d] severity = %v, want %v", evt.Name, evt.Offset, evt.Severity, want)
+		}
+	}
+}
+
+func TestWatchRangeReportsOnlyWatchedOffsets(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.WatchRange(make([]byte, 32), "header", [2]int{4, 8})
+
+	tracker.regions[id][2] = 1
+	tracker.regions[id][6] = 2
+	tracker.DetectChanges()
+
+	if len(tracker.events) != 1 || tracker.events[0].Offset != 6 {
+		t.Fatalf("events = %+v, want single event at offset 6", tracker.events)
+	}
+}
+
+func TestWatchRangeRejectsInvalidRanges(t *testing.T) {
+	tracker := NewMemoryTracker()
+	invalid := [][][2]int{
+		{{0, 40}},
+		{{-1, 4}},
+		{{6, 6}},
+		{{0, 8}, {4, 12}},
+	}
+	for _, ranges := range invalid {
+		if id := tracker.WatchRange(make([]byte, 32), "bad", ranges...); id != 0 {
+			t.Errorf("WatchRange(%v) = %d, want 0", ranges, id)
+		}
+	}
+	if len(tracker.regions) != 0 {
+		t.Errorf("invalid ranges registered %d regions", len(tracker.regions))
+	}
+}
+
+func TestDetectChangesOrdersByRegionThenOffset(t *testing.T) {
+	for run := 0; run < 20; run++ {
+		tracker := NewMemoryTracker()
+		ids := []int{
+			tracker.Watch(make([]byte, 8), "a"),
+			tracker.WatchInts(make([]int, 8), "b"),
+			tracker.Watch(make([]byte, 8), "c"),
+		}
+
+		tracker.regions[ids[2]][5] = 1
+		tracker.regions[ids[2]][1] = 1
+		tracker.intRegions[ids[1]][7] = 1
+		tracker.regions[ids[0]][3] = 1
+		tracker.regions[ids[0]][0] = 1
+		tracker.DetectChanges()
+
+		want := []struct {
+			id, offset int
+		}{
+			{ids[0], 0}, {ids[0], 3}, {ids[1], 7}, {ids[2], 1}, {ids[2], 5},
+		}
+		if len(tracker.events) != len(want) {
+			t.Fatalf("got %d events, want %d", len(tracker.events), len(want))
+		}
+		for i, w := range want {
+			evt := tracker.events[i]
+			if evt.Name != fmt.Sprintf("region_%d", w.id) || evt.Offset != w.offset {
+				t.Fatalf("run %d: event %d = %s[%d], want region_%d[%d]", run, i, evt.Name, evt.Offset, w.id, w.offset)
+			}
+		}
+	}
+}
+
+func TestWatchStructNamesChangedField(t *testing.T) {
+	type config struct {
+		Retries int32
+		Timeout int32
+	}
+
+	tracker := NewMemoryTracker()
+	cfg := &config{Retries: 3, Timeout: 30}
+	if _, err := tracker.WatchStruct(cfg, "cfg"); err != nil {
+		t.Fatalf("WatchStruct: %v", err)
+	}
+
+	cfg.Timeout = 60
+	tracker.DetectChanges()
+
+	if len(tracker.events) == 0 {
+		t.Fatal("no events detected")
+	}
+	for _, evt := range tracker.events {
+		if evt.Name != "cfg.Timeout" {
+			t.Errorf("event at offset %d named %q, want cfg.Timeout", evt.Offset, evt.Name)
+		}
+	}
+}
+
+func TestWatchStructRejectsInvalidArguments(t *testing.T) {
+	tracker := NewMemoryTracker()
+	value := struct{ A int32 }{}
+	withSlice := struct{ B []byte }{}
+	n := 1
+
+	for _, arg := range []interface{}{value, &n, &withSlice} {
+		if _, err := tracker.WatchStruct(arg, "bad"); err == nil {
+			t.Errorf("WatchStruct(%T) succeeded, want error", arg)
+		}
+	}
+}
+
+func TestMergeTrackersNamespacesEvents(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	clock := func() time.Time { return at }
+
+	net := NewMemoryTracker()
+	net.SetClock(clock)
+	netID := net.Watch(make([]byte, 4), "net")
+	net.regions[netID][2] = 7
+	net.DetectChanges()
+
+	disk := NewMemoryTracker()
+	disk.SetClock(clock)
+	diskID := disk.Watch(make([]byte, 4), "disk")
+	disk.regions[diskID][0] = 1
+	disk.regions[diskID][3] = 2
+	disk.DetectChanges()
+
+	merged := MergeTrackers(net, disk)
+
+	want := []MemoryEvent{
+		{Name: fmt.Sprintf("tracker0/region_%d", netID), Offset: 2, OldValue: 0, NewValue: 7, DetectedAt: at},
+		{Name: fmt.Sprintf("tracker1/region_%d", diskID), Offset: 0, OldValue: 0, NewValue: 1, DetectedAt: at},
+		{Name: fmt.Sprintf("tracker1/region_%d", diskID), Offset: 3, OldValue: 0, NewValue: 2, DetectedAt: at},
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("merged %d events, want %d: %+v", len(merged), len(want), merged)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(merged[i], want[i]) {
+			t.Errorf("merged[%d] = %+v, want %+v", i, merged[i], want[i])
+		}
+	}
+
+	if net.events[0].Name != fmt.Sprintf("region_%d", netID) || len(disk.events) != 2 {
+		t.Error("MergeTrackers modified a tracker's events")
+	}
+}
+
+// recordingTB captures failures instead of failing the running test
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertChanges(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch(make([]byte, 8), "buf")
+	name := fmt.Sprintf("region_%d", id)
+
+	tracker.regions[id][1] = 5
+	tracker.regions[id][6] = 9
+	pass := &recordingTB{}
+	tracker.AssertChanges(pass, []MemoryEvent{
+		{Name: name, Offset: 6, OldValue: 0, NewValue: 9},
+		{Name: name, Offset: 1, OldValue: 0, NewValue: 5},
+	})
+	if len(pass.errors) != 0 {
+		t.Errorf("matching events reported failures: %v", pass.errors)
+	}
+
+	tracker.regions[id][2] = 3
+	fail := &recordingTB{}
+	tracker.AssertChanges(fail, []MemoryEvent{
+		{Name: name, Offset: 4, OldValue: 0, NewValue: 1},
+	})
+	if len(fail.errors) != 1 {
+		t.Fatalf("got %d failures, want 1", len(fail.errors))
+	}
+	msg := fail.errors[0]
+	if !strings.Contains(msg, "missing "+name+"[4]: 0 -> 1") || !strings.Contains(msg, "extra   "+name+"[2]: 0 -> 3") {
+		t.Errorf("failure message lacks the diff:\n%s", msg)
+	}
+}
+
+func TestIgnoreSuppressesOffsets(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch(make([]byte, 4), "heartbeat")
+	tracker.Ignore(id, 0)
+
+	tracker.regions[id][0] = 1
+	tracker.regions[id][1] = 2
+	tracker.AssertChanges(t, []MemoryEvent{
+		{Name: fmt.Sprintf("region_%d", id), Offset: 1, OldValue: 0, NewValue: 2},
+	})
+
+	// The ignored change updated the baseline and does not fire again
+	tracker.ignored = make(map[int]map[int]bool)
+	tracker.AssertChanges(t, nil)
+
+	ints := tracker.WatchInts([]int{1, 2}, "ticks")
+	tracker.Ignore(ints)
+	tracker.intRegions[ints][0] = 5
+	tracker.intRegions[ints][1] = 6
+	tracker.AssertChanges(t, nil)
+}
+
+func TestSetClockStampsDetectedAt(t *testing.T) {
+	tracker := NewMemoryTracker()
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tracker.SetClock(func() time.Time { return at })
+
+	bytesID := tracker.Watch(make([]byte, 4), "bytes")
+	intsID := tracker.WatchInts([]int{1, 2}, "ints")
+	tracker.regions[bytesID][0] = 1
+	tracker.regions[bytesID][3] = 1
+	tracker.intRegions[intsID][1] = 5
+	tracker.DetectChanges()
+
+	if len(tracker.events) != 3 {
+		t.Fatalf("got %d events, want 3", len(tracker.events))
+	}
+	for i, evt := range tracker.events {
+		if !evt.DetectedAt.Equal(at) {
+			t.Errorf("events[%d].DetectedAt = %v, want %v", i, evt.DetectedAt, at)
+		}
+	}
+}
+
+func TestWatchMultiReportsLogicalOffsets(t *testing.T) {
+	tracker := NewMemoryTracker()
+	header, payload := make([]byte, 8), make([]byte, 8)
+	id := tracker.WatchMulti("packet", header, payload)
+
+	payload[0] = 0x7f
+	tracker.AssertChanges(t, []MemoryEvent{
+		{Name: fmt.Sprintf("region_%d", id), Offset: 8, OldValue: 0, NewValue: 0x7f},
+	})
+
+	if info, _ := tracker.RegionInfo(id); info.Name != "packet" || info.Size != 16 {
+		t.Errorf("RegionInfo = %+v, want packet of 16 bytes", info)
+	}
+
+	if !tracker.Unwatch(id) {
+		t.Fatal("Unwatch reported the region as unknown")
+	}
+	header[0] = 1
+	tracker.AssertChanges(t, nil)
+	if _, ok := tracker.RegionInfo(id); ok || len(tracker.segments) != 0 {
+		t.Error("Unwatch left composite region state behind")
+	}
+}
+
+func TestSetRateLimitThrottlesEvents(t *testing.T) {
+	tracker := NewMemoryTracker()
+	now := time.Unix(1000, 0)
+	tracker.SetClock(func() time.Time { return now })
+
+	id := tracker.Watch(make([]byte, 1), "hot")
+	tracker.SetRateLimit(id, 2)
+
+	// Ten changes 10ms apart: the burst allows 2 and the refill stays below one token
+	for i := 1; i <= 10; i++ {
+		now = now.Add(10 * time.Millisecond)
+		tracker.regions[id][0] = byte(i)
+		tracker.DetectChanges()
+	}
+	info, _ := tracker.RegionInfo(id)
+	if info.ChangeCount != 2 || info.Throttled != 8 {
+		t.Fatalf("after burst: ChangeCount %d, Throttled %d, want 2 and 8", info.ChangeCount, info.Throttled)
+	}
+
+	// Half a second later one token has refilled
+	now = now.Add(500 * time.Millisecond)
+	tracker.regions[id][0] = 99
+	tracker.DetectChanges()
+	info, _ = tracker.RegionInfo(id)
+	if info.ChangeCount != 3 || info.Throttled != 8 {
+		t.Errorf("after refill: ChangeCount %d, Throttled %d, want 3 and 8", info.ChangeCount, info.Throttled)
+	}
+	if last := tracker.events[len(tracker.events)-1]; last.OldValue != 10 || last.NewValue != 99 {
+		t.Errorf("last event = %+v, want baseline kept up to date through throttling", last)
+	}
+}
+
+func TestRegionIDsStartAtOne(t *testing.T) {
+	tracker := NewMemoryTracker()
+	if id := tracker.Watch(make([]byte, 4), "first"); id != 1 {
+		t.Errorf("first Watch returned id %d, want 1", id)
+	}
+	if _, ok := tracker.RegionInfo(0); ok {
+		t.Error("RegionInfo(0) found a region")
+	}
+	if tracker.Unwatch(0) {
+		t.Error("Unwatch(0) reported success")
+	}
+}
+
+func TestSetDedupWithinPassKeepsLastValue(t *testing.T) {
+	run := func(dedup bool, want []MemoryEvent) {
+		tracker := NewMemoryTracker()
+		tracker.SetDedupWithinPass(dedup)
+
+		// Two parts both placed at logical offset 0 cover offset 1 twice
+		a, b := make([]byte, 2), make([]byte, 2)
+		tracker.WatchMultiAt("pair", []int{0, 0}, a, b)
+		a[1], b[1] = 5, 6
+		tracker.AssertChanges(t, want)
+	}
+
+	run(false, []MemoryEvent{
+		{Name: "region_1", Offset: 1, OldValue: 0, NewValue: 5},
+		{Name: "region_1", Offset: 1, OldValue: 0, NewValue: 6},
+	})
+	run(true, []MemoryEvent{{Name: "region_1", Offset: 1, OldValue: 0, NewValue: 6}})
+
+	tracker := NewMemoryTracker()
+	tracker.SetDedupWithinPass(true)
+	id := tracker.Watch(make([]byte, 2), "buf")
+	tracker.regions[id][1] = 1
+	tracker.DetectChanges()
+	tracker.regions[id][1] = 2
+	tracker.DetectChanges()
+	if len(tracker.events) != 2 {
+		t.Errorf("dedup merged events across passes: %+v", tracker.events)
+	}
+}
+
+func TestWatchMultiAtOverlappingViews(t *testing.T) {
+	tracker := NewMemoryTracker()
+	buf := make([]byte, 8)
+	if id := tracker.WatchMultiAt("bad", []int{0}, buf[:2], buf[2:4]); id != 0 {
+		t.Errorf("WatchMultiAt with too few offsets = %d, want 0", id)
+	}
+
+	// buf[2:6] seen both as the tail of the first view and as the second
+	id := tracker.WatchMultiAt("rec", []int{0, 2}, buf[0:6], buf[2:6])
+	tracker.Ignore(id, 5)
+	tracker.SetDedupWithinPass(true)
+	buf[4], buf[5] = 9, 9
+	tracker.AssertChanges(t, []MemoryEvent{{Name: "region_1", Offset: 4, OldValue: 0, NewValue: 9}})
+}
+
+func TestSetRegionTagsCopiesTags(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch(make([]byte, 2), "sock")
+
+	tags := map[string]string{"subsystem": "net"}
+	tracker.SetRegionTags(id, tags)
+	tags["subsystem"] = "disk"
+
+	tracker.regions[id][0] = 1
+	tracker.regions[id][1] = 1
+	tracker.DetectChanges()
+	if len(tracker.events) != 2 {
+		t.Fatalf("got %d events, want 2", len(tracker.events))
+	}
+
+	first, second := tracker.events[0].Tags, tracker.events[1].Tags
+	if first["subsystem"] != "net" {
+		t.Fatalf("event tags = %v, want subsystem=net", first)
+	}
+	first["subsystem"] = "mutated"
+	if second["subsystem"] != "net" || tracker.tags[id]["subsystem"] != "net" {
+		t.Error("mutating one event's tags leaked into another event or the region")
+	}
+}
+
+func TestChecksumTracksState(t *testing.T) {
+	tracker := NewMemoryTracker()
+	buf := tracker.regions[tracker.Watch(make([]byte, 16), "buf")]
+	ints := tracker.intRegions[tracker.WatchInts([]int{1, 2, 3}, "ints")]
+
+	before := tracker.Checksum()
+	if again := tracker.Checksum(); again != before {
+		t.Fatalf("Checksum not stable: %x then %x", before, again)
+	}
+
+	buf[7] ^= 0x01
+	if tracker.Checksum() == before {
+		t.Error("Checksum unchanged after a byte flip")
+	}
+	buf[7] ^= 0x01
+	if got := tracker.Checksum(); got != before {
+		t.Errorf("Checksum after rollback = %x, want %x", got, before)
+	}
+
+	ints[2] = 4
+	if tracker.Checksum() == before {
+		t.Error("Checksum unchanged after an int change")
+	}
+}
+
+// byteSum is a deliberately weak hash.Hash64: the sum of all bytes written,
+// so reordering bytes keeps the hash
+type byteSum struct{ sum uint64 }
+
+func (h *byteSum) Write(p []byte) (int, error) {
+	for _, b := range p {
+		h.sum += uint64(b)
+	}
+	return len(p), nil
+}
+
+func (h *byteSum) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.sum)
+}
+
+func (h *byteSum) Reset()         { h.sum = 0 }
+func (h *byteSum) Size() int      { return 8 }
+func (h *byteSum) BlockSize() int { return 1 }
+func (h *byteSum) Sum64() uint64  { return h.sum }
+
+func TestSetHasherDrivesChecksumAndHashDetection(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.SetHasher(func() hash.Hash64 { return &byteSum{} })
+	tracker.SetHashDetection(true)
+	id := tracker.Watch([]byte{1, 2, 3}, "buf")
+
+	// The region id is hashed as 8 bytes ahead of the contents
+	if got, want := tracker.Checksum(), uint64(id+1+2+3); got != want {
+		t.Errorf("Checksum() = %d, want %d", got, want)
+	}
+
+	// Swapping bytes keeps the byte sum, so hash detection skips the diff
+	tracker.regions[id][0], tracker.regions[id][1] = 2, 1
+	tracker.DetectChanges()
+	if len(tracker.events) != 0 {
+		t.Fatalf("got %d events for a sum-preserving swap, want 0", len(tracker.events))
+	}
+	if got, want := tracker.Checksum(), uint64(id+1+2+3); got != want {
+		t.Errorf("Checksum() after swap = %d, want %d", got, want)
+	}
+
+	tracker.regions[id][2] = 4
+	tracker.DetectChanges()
+	if len(tracker.events) == 0 {
+		t.Error("no events after the byte sum changed")
+	}
+
+	tracker.SetHasher(nil)
+	if tracker.Checksum() == uint64(id+1+2+4) {
+		t.Error("Checksum still uses the byte sum after SetHasher(nil)")
+	}
+}
+
+func TestDroppedByRegion(t *testing.T) {
+	tracker := NewMemoryTracker()
+	now := time.Unix(0, 0)
+	tracker.SetClock(func() time.Time { return now })
+
+	slow := tracker.Watch(make([]byte, 1), "slow")
+	fast := tracker.Watch(make([]byte, 1), "fast")
+	quiet := tracker.Watch(make([]byte, 1), "quiet")
+	tracker.SetRateLimit(slow, 1)
+	tracker.SetRateLimit(fast, 4)
+
+	for i := 1; i <= 10; i++ {
+		now = now.Add(time.Millisecond)
+		tracker.regions[slow][0] = byte(i)
+		tracker.regions[fast][0] = byte(i)
+		tracker.regions[quiet][0] = byte(i)
+		tracker.DetectChanges()
+	}
+
+	want := map[int]uint64{slow: 9, fast: 6}
+	if got := tracker.DroppedByRegion(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DroppedByRegion() = %v, want %v", got, want)
+	}
+}
+
+func TestSubscribeFansOutAndCountsDrops(t *testing.T) {
+	const passes, perPass = 20, 10
+	tracker := NewMemoryTracker()
+	id := tracker.Watch(make([]byte, perPass), "buf")
+
+	fast1, unsub1 := tracker.Subscribe()
+	fast2, unsub2 := tracker.Subscribe()
+	slow, unsubSlow := tracker.Subscribe()
+
+	// The fast subscribers acknowledge each full pass, so the tracker never
+	// outruns them; the slow one is not read until the end
+	received := make([]int, 2)
+	acks := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, ch := range []<-chan MemoryEvent{fast1, fast2} {
+		wg.Add(1)
+		go func(i int, ch <-chan MemoryEvent) {
+			defer wg.Done()
+			for range ch {
+				received[i]++
+				if received[i]%perPass == 0 {
+					acks <- struct{}{}
+				}
+			}
+		}(i, ch)
+	}
+
+	for pass := 1; pass <= passes; pass++ {
+		for i := range tracker.regions[id] {
+			tracker.regions[id][i] = byte(pass)
+		}
+		tracker.DetectChanges()
+		<-acks
+		<-acks
+	}
+
+	want := map[<-chan MemoryEvent]uint64{slow: passes*perPass - subscribeBuffer}
+	if got := tracker.DroppedBySubscriber(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DroppedBySubscriber() = %v, want only the slow subscriber with %v", got, want[slow])
+	}
+
+	unsub1()
+	unsub2()
+	unsub2()
+	wg.Wait()
+	for i, n := range received {
+		if n != passes*perPass {
+			t.Errorf("fast subscriber %d received %d events, want %d", i, n, passes*perPass)
+		}
+	}
+
+	unsubSlow()
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != subscribeBuffer {
+		t.Errorf("slow subscriber received %d events, want %d", n, subscribeBuffer)
+	}
+
+	tracker.regions[id][0] = 0xFF
+	tracker.DetectChanges()
+	if len(tracker.subs) != 0 {
+		t.Errorf("%d subscribers remain after unsubscribing", len(tracker.subs))
+	}
+}
+
+func TestSetLoggerCapturesWatchMessage(t *testing.T) {
+	tracker := NewMemoryTracker()
+	var logged []string
+	tracker.SetLogger(func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	})
+
+	id := tracker.Watch(make([]byte, 4), "buf")
+
+	want := fmt.Sprintf("  ✓ Watching region %d: buf\n", id)
+	if len(logged) != 1 || logged[0] != want {
+		t.Errorf("logged %q, want [%q]", logged, want)
+	}
+}
+
+func TestDefaultLoggerPrintsNothing(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	tracker := NewMemoryTracker()
+	tracker.Watch(make([]byte, 4), "buf")
+	tracker.WatchInts([]int{1}, "ints")
+
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if len(out) != 0 {
+		t.Errorf("default logger printed %q", out)
+	}
+}
+
+func TestReplayEventsReconstructsBuffer(t *testing.T) {
+	tracker := NewMemoryTracker()
+	data := []byte{1, 2, 3, 4, 5}
+	initial := append([]byte(nil), data...)
+	id := tracker.Watch(data, "buf")
+
+	data[1] = 20
+	data[4] = 50
+	tracker.regions[id] = data
+	tracker.DetectChanges()
+
+	states := ReplayEvents(initial, tracker.events)
+	if len(states) != len(tracker.events) {
+		t.Fatalf("got %d states for %d events", len(states), len(tracker.events))
+	}
+	if final := states[len(states)-1]; !reflect.DeepEqual(final, data) {
+		t.Errorf("final state = %v, want %v", final, data)
+	}
+	if !reflect.DeepEqual(initial, []byte{1, 2, 3, 4, 5}) {
+		t.Errorf("ReplayEvents modified the initial buffer: %v", initial)
+	}
+
+	bad := append(tracker.events, MemoryEvent{Name: "buf", Offset: 9, NewValue: 7})
+	states, warnings := ReplayEventsWithWarnings(initial, bad)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "offset 9") {
+		t.Errorf("warnings = %q, want one about offset 9", warnings)
+	}
+	if last := states[len(states)-1]; !reflect.DeepEqual(last, data) {
+		t.Errorf("skipped event changed the state to %v", last)
+	}
+}
+
+func TestOffsetHeatmapAccumulatesAcrossPasses(t *testing.T) {
+	tracker := NewMemoryTracker()
+	data := make([]byte, 10)
+	id := tracker.Watch(data, "buf")
+
+	for pass := 1; pass <= 3; pass++ {
+		data[3] = byte(pass)
+		if pass == 2 {
+			data[7] = 0xFF
+		}
+		tracker.regions[id] = data
+		tracker.DetectChanges()
+	}
+
+	want := map[int]int{3: 3, 7: 1}
+	if got := tracker.OffsetHeatmap(id); !reflect.DeepEqual(got, want) {
+		t.Errorf("OffsetHeatmap = %v, want %v", got, want)
+	}
+}
+
+func TestReportPrintsTable(t *testing.T) {
+	tracker := NewMemoryTracker()
+	data := make([]byte, 8)
+	id := tracker.Watch(data, "buf")
+	data[2] = 42
+	data[5] = 255
+	tracker.regions[id] = data
+	tracker.DetectChanges()
+
+	var out strings.Builder
+	tracker.Report(&out)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+
+	if len(lines) != 4 {
+		t.Fatalf("Report printed %d lines, want header, 2 rows and summary:\n%s", len(lines), out.String())
+	}
+	if fields := strings.Fields(lines[0]); !reflect.DeepEqual(fields, []string{"REGION", "OFFSET", "OLD", "NEW"}) {
+		t.Errorf("header = %q", lines[0])
+	}
+	if name := fmt.Sprintf("region_%d", id); !strings.HasPrefix(lines[1], name) || !strings.Contains(lines[1], "0x00 (0)") || !strings.Contains(lines[1], "0x2a (42)") {
+		t.Errorf("row = %q, want %s with 0x00 (0) → 0x2a (42)", lines[1], name)
+	}
+	if lines[3] != "2 events in 1 regions" {
+		t.Errorf("summary = %q", lines[3])
+	}
+}
+
+func TestSetComparatorIgnoresSmallFloatChanges(t *testing.T) {
+	tracker := NewMemoryTracker()
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, math.Float64bits(1.0))
+	id := tracker.Watch(data, "temperature")
+
+	tracker.SetComparator(id, func(old, new []byte) []Diff {
+		before := math.Float64frombits(binary.LittleEndian.Uint64(old))
+		after := math.Float64frombits(binary.LittleEndian.Uint64(new))
+		if math.Abs(after-before) < 0.01 {
+			return nil
+		}
+		var diffs []Diff
+		for i := range new {
+			if old[i] != new[i] {
+				diffs = append(diffs, Diff{Offset: i})
+			}
+		}
+		return diffs
+	})
+
+	binary.LittleEndian.PutUint64(data, math.Float64bits(1.001))
+	tracker.regions[id] = data
+	tracker.DetectChanges()
+	if len(tracker.events) != 0 {
+		t.Fatalf("sub-threshold change produced %d events", len(tracker.events))
+	}
+
+	binary.LittleEndian.PutUint64(data, math.Float64bits(2.0))
+	tracker.DetectChanges()
+	if len(tracker.events) == 0 {
+		t.Fatal("change above the threshold produced no events")
+	}
+	if final := ReplayEvents([]byte{0, 0, 0, 0, 0, 0, 0xF0, 0x3F}, tracker.events); !reflect.DeepEqual(final[len(final)-1], data) {
+		t.Errorf("events do not rebuild the new value: %v", final[len(final)-1])
+	}
+}
+
+func TestSetCaptureStackRecordsCaller(t *testing.T) {
+	tracker := NewMemoryTracker()
+	data := make([]byte, 4)
+	id := tracker.Watch(data, "buf")
+	tracker.SetCaptureStack(true)
+
+	data[1] = 7
+	tracker.regions[id] = data
+	tracker.DetectChanges()
+
+	if len(tracker.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(tracker.events))
+	}
+	found := false
+	for _, frame := range tracker.events[0].StackFrames() {
+		if strings.HasSuffix(frame.Function, "TestSetCaptureStackRecordsCaller") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("stack frames do not include the test: %v", tracker.events[0].StackFrames())
+	}
+
+	tracker.SetCaptureStack(false)
+	data[2] = 9
+	tracker.DetectChanges()
+	if stack := tracker.events[1].Stack; stack != nil {
+		t.Errorf("Stack = %v with capture disabled", stack)
+	}
+}
+
+func TestDiffBytes(t *testing.T) {
+	old := []byte{0x00, 0x11, 0x22, 0x33}
+	cur := []byte{0x00, 0x12, 0x22, 0xFF}
+
+	got, err := DiffBytes(old, cur, "snapshot")
+	if err != nil {
+		t.Fatalf("DiffBytes: %v", err)
+	}
+	want := []MemoryEvent{
+		{Name: "snapshot", Offset: 1, OldValue: 0x11, NewValue: 0x12},
+		{Name: "snapshot", Offset: 3, OldValue: 0x33, NewValue: 0xFF},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffBytes = %+v, want %+v", got, want)
+	}
+
+	if events, err := DiffBytes(old, cur[:2], "snapshot"); err == nil || events != nil {
+		t.Errorf("DiffBytes with mismatched lengths = %v, %v, want an error", events, err)
+	}
+}
+
+func TestAddSignatureFiresAtOffset(t *testing.T) {
+	tracker := NewMemoryTracker()
+	data := make([]byte, 16)
+	id := tracker.Watch(data, "stack")
+	tracker.AddSignature("canary-overwrite", []byte{0xDE, 0xFF, 0xEF})
+
+	data[9], data[10], data[11] = 0xDE, 0xAD, 0xEF
+	tracker.regions[id] = data
+	tracker.DetectChanges()
+
+	want := []SignatureEvent{{Name: "canary-overwrite", RegionID: id, Offset: 9}}
+	if got := tracker.SignatureEvents(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SignatureEvents = %+v, want %+v", got, want)
+	}
+	if len(tracker.events) != 3 {
+		t.Errorf("got %d byte events alongside the signature, want 3", len(tracker.events))
+	}
+
+	tracker.DetectChanges()
+	if n := len(tracker.SignatureEvents()); n != 1 {
+		t.Errorf("unchanged region was rescanned, %d signature events", n)
+	}
+}
+
+func TestMemoryEventJSONUsesSnakeCase(t *testing.T) {
+	data, err := json.Marshal(MemoryEvent{Name: "buf", Offset: 2, OldValue: 1, NewValue: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"name"`, `"offset"`, `"old_value"`, `"new_value"`, `"detected_at"`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("%s lacks key %s", data, key)
+		}
+	}
+	if strings.Contains(string(data), `"tags"`) || strings.Contains(string(data), `"stack"`) {
+		t.Errorf("%s includes empty optional fields", data)
+	}
+}
+
+func TestDetectChangesReportsResize(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch(make([]byte, 20), "buf")
+
+	grown := make([]byte, 40)
+	tracker.regions[id] = grown
+	tracker.DetectChanges()
+
+	want := []ResizeEvent{{RegionID: id, OldLen: 20, NewLen: 40}}
+	if got := tracker.ResizeEvents(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ResizeEvents = %+v, want %+v", got, want)
+	}
+	if len(tracker.events) != 0 {
+		t.Errorf("resize produced %d byte events", len(tracker.events))
+	}
+
+	grown[30] = 5
+	tracker.DetectChanges()
+	if len(tracker.events) != 1 || tracker.events[0].Offset != 30 {
+		t.Errorf("after re-baselining got events %+v, want one at offset 30", tracker.events)
+	}
+	if n := len(tracker.ResizeEvents()); n != 1 {
+		t.Errorf("got %d resize events, want 1", n)
+	}
+}
+
+func TestExportTraceEvents(t *testing.T) {
+	tracker := NewMemoryTracker()
+	now := time.Unix(1700000000, 250000)
+	tracker.SetClock(func() time.Time { return now })
+	id := tracker.Watch(make([]byte, 4), "buf")
+
+	tracker.regions[id][2] = 9
+	tracker.DetectChanges()
+
+	var buf strings.Builder
+	if err := tracker.ExportTraceEvents(&buf); err != nil {
+		t.Fatalf("ExportTraceEvents: %v", err)
+	}
+
+	var trace []map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.String()), &trace); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, buf.String())
+	}
+	if len(trace) != 1 {
+		t.Fatalf("got %d trace events, want 1", len(trace))
+	}
+
+	evt := trace[0]
+	for _, key := range []string{"name", "cat", "ph", "ts", "pid", "tid", "args"} {
+		if _, ok := evt[key]; !ok {
+			t.Errorf("trace event lacks %q: %v", key, evt)
+		}
+	}
+	if evt["ph"] != "i" || evt["cat"] != tracker.events[0].Name {
+		t.Errorf("ph, cat = %v, %v, want i, %s", evt["ph"], evt["cat"], tracker.events[0].Name)
+	}
+	if ts, want := evt["ts"], 1700000000000250.0; ts != want {
+		t.Errorf("ts = %v, want %v", ts, want)
+	}
+	args, _ := evt["args"].(map[string]interface{})
+	if args["offset"] != 2.0 || args["new_value"] != 9.0 {
+		t.Errorf("args = %v, want offset 2 and new_value 9", args)
+	}
+}
+
+func TestRedundantWritesCountsWithoutEvent(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch([]byte{5, 0}, "buf")
+	buf := tracker.regions[id]
+
+	buf[0] = 6
+	tracker.AssertChanges(t, []MemoryEvent{{Name: fmt.Sprintf("region_%d", id), Offset: 0, OldValue: 5, NewValue: 6}})
+	if got := tracker.RedundantWrites(id); got != 0 {
+		t.Errorf("RedundantWrites after 5 -> 6 = %d, want 0", got)
+	}
+
+	// Writing back the baseline value is redundant and emits no event
+	buf[0] = 5
+	tracker.AssertChanges(t, nil)
+	if got := tracker.RedundantWrites(id); got != 1 {
+		t.Errorf("RedundantWrites after 6 -> 5 = %d, want 1", got)
+	}
+
+	// The rolling baseline moved back to 5, so the byte changes again
+	buf[0] = 6
+	tracker.AssertChanges(t, []MemoryEvent{{Name: fmt.Sprintf("region_%d", id), Offset: 0, OldValue: 5, NewValue: 6}})
+
+	tracker.Unwatch(id)
+	if got := tracker.RedundantWrites(id); got != 0 {
+		t.Errorf("RedundantWrites after Unwatch = %d, want 0", got)
+	}
+}
+
+func TestRedundantWritesCountsWords(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch([]byte{1, 2, 3, 4}, "buf")
+	tracker.SetGranularity(id, 2)
+	buf := tracker.regions[id]
+
+	buf[0], buf[3] = 9, 9
+	tracker.DetectChanges()
+	buf[0] = 1
+	tracker.AssertChanges(t, nil)
+	if got := tracker.RedundantWrites(id); got != 1 {
+		t.Errorf("RedundantWrites after word 0 went back = %d, want 1", got)
+	}
+
+	// Word 1 is back only once both of its bytes are
+	buf[2] = 0
+	tracker.DetectChanges()
+	buf[2], buf[3] = 3, 4
+	tracker.AssertChanges(t, nil)
+	if got := tracker.RedundantWrites(id); got != 2 {
+		t.Errorf("RedundantWrites after word 1 went back = %d, want 2", got)
+	}
+}
+
+func TestSetBaselineSuppressesKnownState(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.SetHashDetection(true)
+	buf := []byte{1, 2, 3, 4}
+	id := tracker.Watch(buf, "buf")
+	buf = tracker.regions[id]
+
+	buf[0], buf[3] = 9, 9
+	if err := tracker.SetBaseline(id, []byte{9, 2, 3, 9}); err != nil {
+		t.Fatalf("SetBaseline: %v", err)
+	}
+	tracker.DetectChanges()
+	if len(tracker.events) != 0 {
+		t.Fatalf("got %d events against the new baseline, want 0", len(tracker.events))
+	}
+
+	buf[1] = 7
+	tracker.DetectChanges()
+	if len(tracker.events) != 1 || tracker.events[0].Offset != 1 || tracker.events[0].OldValue != 2 {
+		t.Errorf("events = %+v, want one change of offset 1 from 2", tracker.events)
+	}
+
+	if err := tracker.SetBaseline(id, []byte{1}); err == nil {
+		t.Error("SetBaseline with a short baseline succeeded")
+	}
+	if err := tracker.SetBaseline(id+1, []byte{1, 2, 3, 4}); err == nil {
+		t.Error("SetBaseline of an unknown id succeeded")
+	}
+	ints := tracker.WatchInts([]int{1}, "ints")
+	if err := tracker.SetBaseline(ints, make([]byte, 8)); err == nil {
+		t.Error("SetBaseline of an int region succeeded")
+	}
+}
+
+func TestSetSizeBudgetFiresOnCrossing(t *testing.T) {
+	tracker := NewMemoryTracker()
+	var totals []int
+	tracker.SetSizeBudget(100, func(total int) { totals = append(totals, total) })
+
+	tracker.Watch(make([]byte, 60), "a")
+	if len(totals) != 0 {
+		t.Fatalf("callback fired at 60 bytes: %v", totals)
+	}
+	b := tracker.WatchMulti("b", make([]byte, 30), make([]byte, 20))
+	tracker.Watch(make([]byte, 10), "c")
+	if want := []int{110}; !reflect.DeepEqual(totals, want) {
+		t.Fatalf("totals = %v, want %v", totals, want)
+	}
+
+	tracker.Unwatch(b)
+	tracker.WatchInts(make([]int, 5), "d")
+	if want := []int{110, 110}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("totals after re-crossing = %v, want %v", totals, want)
+	}
+}
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.SetClock(func() time.Time { return time.Unix(1700000000, 0).UTC() })
+	buf := tracker.Watch([]byte{1, 2, 3}, "buf")
+	ints := tracker.WatchInts([]int{10, 20}, "ints")
+	tracker.SetRegionSeverity(buf, Critical)
+	tracker.SetRegionTags(ints, map[string]string{"owner": "cache"})
+
+	tracker.regions[buf][1] = 9
+	tracker.intRegions[ints][0] = 11
+	tracker.DetectChanges()
+
+	var state strings.Builder
+	if err := tracker.SaveState(&state); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	loaded, err := LoadState(strings.NewReader(state.String()))
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+
+	for _, check := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"regions", loaded.regions, tracker.regions},
+		{"initial", loaded.initial, tracker.initial},
+		{"intRegions", loaded.intRegions, tracker.intRegions},
+		{"intInitial", loaded.intInitial, tracker.intInitial},
+		{"names", loaded.names, tracker.names},
+		{"changeCounts", loaded.changeCounts, tracker.changeCounts},
+		{"severities", loaded.severities, tracker.severities},
+		{"tags", loaded.tags, tracker.tags},
+		{"events", loaded.events, tracker.events},
+		{"regionCount", loaded.regionCount, tracker.regionCount},
+	} {
+		if !reflect.DeepEqual(check.got, check.want) {
+			t.Errorf("reloaded %s = %v, want %v", check.name, check.got, check.want)
+		}
+	}
+
+	loaded.DetectChanges()
+	if len(loaded.events) != len(tracker.events) {
+		t.Errorf("first DetectChanges after LoadState added %v", loaded.events[len(tracker.events):])
+	}
+	if id := loaded.Watch([]byte{0}, "new"); id != tracker.regionCount+1 {
+		t.Errorf("new region id = %d, want %d", id, tracker.regionCount+1)
+	}
+
+	if _, err := LoadState(strings.NewReader("not gob")); err == nil {
+		t.Error("LoadState of garbage succeeded")
+	}
+}
+
+func TestSaveStateKeepsEventFiltersAndNames(t *testing.T) {
+	type config struct {
+		A, B int32
+	}
+
+	tracker := NewMemoryTracker()
+	window := tracker.WatchRange(make([]byte, 8), "window", [2]int{2, 4})
+	ignored := tracker.Watch(make([]byte, 4), "ignored")
+	tracker.Ignore(ignored, 1)
+	silent := tracker.Watch(make([]byte, 2), "silent")
+	tracker.Ignore(silent)
+	cfg := &config{}
+	cfgID, _ := tracker.WatchStruct(cfg, "cfg")
+	pairs := []config{{}, {}}
+	pairsID, _ := WatchSliceOf(tracker, pairs, "pairs")
+	counts := []uint16{0, 0}
+	countsID, _ := WatchSliceOf(tracker, counts, "counts")
+	part := make([]byte, 2)
+	multi := tracker.WatchMultiAt("multi", []int{4, 0}, part, make([]byte, 2))
+
+	var state strings.Builder
+	if err := tracker.SaveState(&state); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	loaded, err := LoadState(strings.NewReader(state.String()))
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+
+	// The same writes, to the live memory and to the reloaded copies
+	cfg.B = 5
+	pairs[1].A = 6
+	counts[1] = 7
+	part[0] = 3
+	for _, mt := range []*MemoryTracker{tracker, loaded} {
+		mt.regions[window][0] = 1
+		mt.regions[window][3] = 1
+		mt.regions[ignored][1] = 1
+		mt.regions[ignored][2] = 1
+		mt.regions[silent][0] = 1
+	}
+	loaded.regions[cfgID][4] = 5
+	loaded.regions[pairsID][8] = 6
+	loaded.regions[countsID][2] = 7
+	loaded.regions[multi][0] = 3
+
+	want := []MemoryEvent{
+		{Name: "region_1", Offset: 3, OldValue: 0, NewValue: 1},
+		{Name: "region_2", Offset: 2, OldValue: 0, NewValue: 1},
+		{Name: "cfg.B", Offset: 4, OldValue: 0, NewValue: 5},
+		{Name: "pairs[1].A", Offset: 8, OldValue: 0, NewValue: 6},
+		{Name: "counts[1]", Offset: 2, OldValue: 0, NewValue: 7},
+		{Name: "region_7", Offset: 4, OldValue: 0, NewValue: 3},
+	}
+	tracker.AssertChanges(t, want)
+	loaded.AssertChanges(t, want)
+}
+
+func TestWatchSliceOffsetsAreRelative(t *testing.T) {
+	tracker := NewMemoryTracker()
+	data := make([]byte, 1<<16)
+	id := tracker.WatchSlice(data, 10, 10, "header")
+	if id == 0 {
+		t.Fatal("WatchSlice refused an in-bounds window")
+	}
+	if got := len(tracker.initial[id]); got != 10 {
+		t.Errorf("baseline holds %d bytes, want only the 10-byte window", got)
+	}
+
+	data[9] = 1
+	data[15] = 2
+	data[20] = 3
+	tracker.AssertChanges(t, []MemoryEvent{{Name: "region_1", Offset: 5, OldValue: 0, NewValue: 2}})
+
+	for _, bounds := range [][2]int{{-1, 4}, {0, 0}, {len(data) - 4, 5}, {len(data), 1}} {
+		if id := tracker.WatchSlice(data, bounds[0], bounds[1], "bad"); id != 0 {
+			t.Errorf("WatchSlice(offset %d, length %d) = %d, want 0", bounds[0], bounds[1], id)
+		}
+	}
+}
+
+func TestSetGranularityEmitsWordEvents(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch(make([]byte, 10), "words")
+	tracker.SetGranularity(id, 4)
+	buf := tracker.regions[id]
+
+	buf[4], buf[6] = 0x01, 0x02
+	buf[9] = 0xFF
+	tracker.AssertChanges(t, []MemoryEvent{
+		{Name: "region_1", Offset: 1, OldValue: 0, NewValue: 0x020001},
+		{Name: "region_1", Offset: 2, OldValue: 0, NewValue: 0xFF00},
+	})
+
+	tracker.SetGranularity(id, 3)
+	tracker.SetGranularity(id, 1)
+	buf[0] = 7
+	tracker.AssertChanges(t, []MemoryEvent{{Name: "region_1", Offset: 0, OldValue: 0, NewValue: 7}})
+}
+
+func TestOnRestoreFiresWhenRegionReverts(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch([]byte{1, 2, 3, 4}, "buf")
+	other := tracker.Watch([]byte{9}, "other")
+	buf := tracker.regions[id]
+
+	var restored []int
+	tracker.OnRestore(func(regionID int) { restored = append(restored, regionID) })
+
+	tracker.DetectChanges()
+	buf[1], buf[3] = 20, 40
+	tracker.DetectChanges()
+	buf[1] = 2
+	tracker.DetectChanges()
+	if len(restored) != 0 {
+		t.Fatalf("restore fired while the region still differs: %v", restored)
+	}
+
+	buf[3] = 4
+	tracker.DetectChanges()
+	tracker.DetectChanges()
+	if want := []int{id}; !reflect.DeepEqual(restored, want) {
+		t.Errorf("restored = %v, want %v", restored, want)
+	}
+	if tracker.dirty[other] {
+		t.Error("untouched region marked dirty")
+	}
+}
+
+func TestOnRestoreKeepsWatchTimeOriginalAfterSetBaseline(t *testing.T) {
+	tracker := NewMemoryTracker()
+	id := tracker.Watch([]byte{1, 2}, "buf")
+	buf := tracker.regions[id]
+
+	var restored []int
+	tracker.OnRestore(func(regionID int) { restored = append(restored, regionID) })
+
+	// Rebasing before the first pass reports the region against the new
+	// baseline without moving the original
+	if err := tracker.SetBaseline(id, []byte{0, 2}); err != nil {
+		t.Fatalf("SetBaseline: %v", err)
+	}
+	tracker.AssertChanges(t, []MemoryEvent{{Name: fmt.Sprintf("region_%d", id), Offset: 0, OldValue: 0, NewValue: 1}})
+	if got := tracker.RedundantWrites(id); got != 0 {
+		t.Errorf("RedundantWrites after SetBaseline = %d, want 0", got)
+	}
+
+	buf[0] = 7
+	tracker.DetectChanges()
+	buf[0] = 1
+	tracker.DetectChanges()
+	if want := []int{id}; !reflect.DeepEqual(restored, want) {
+		t.Errorf("restored = %v, want %v once back at the Watch-time contents", restored, want)
+	}
+}
+
+// Run with -race: the writer and DetectChanges share buf through RegionLock
+func TestConsistentSnapshotUnderConcurrentWrites(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.SetConsistentSnapshot(true)
+	buf := make([]byte, 64)
+	tracker.WatchSlice(buf, 0, len(buf), "buf")
+	lock := tracker.RegionLock()
+
+	// Every write sets the whole buffer to one value, so a snapshot taken
+	// mid-write would show two values at once
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := byte(1); ; v++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			lock.Lock()
+			for i := range buf {
+				buf[i] = v
+			}
+			lock.Unlock()
+			runtime.Gosched()
+		}
+	}()
+
+	prev := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for pass, changed := 0, 0; changed < 100; pass++ {
+		if time.Now().After(deadline) {
+			t.Fatalf("saw %d changing passes in 5s, want 100", changed)
+		}
+		start := len(tracker.events)
+		tracker.DetectChanges()
+		events := tracker.events[start:]
+		if len(events) == 0 {
+			runtime.Gosched()
+			continue
+		}
+		changed++
+		if len(events) != len(buf) {
+			t.Fatalf("pass %d: %d events, want 0 or %d", pass, len(events), len(buf))
+		}
+		for _, evt := range events {
+			if evt.OldValue != prev || evt.NewValue != events[0].NewValue {
+				t.Fatalf("pass %d: torn event %+v, want %d -> %d", pass, evt, prev, events[0].NewValue)
+			}
+		}
+		prev = events[0].NewValue
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func TestWatchSliceOfNamesElementField(t *testing.T) {
+	type pair struct {
+		A, B int32
+	}
+
+	tracker := NewMemoryTracker()
+	pairs := []pair{{1, 2}, {3, 4}}
+	if _, err := WatchSliceOf(tracker, pairs, "pairs"); err != nil {
+		t.Fatalf("WatchSliceOf: %v", err)
+	}
+
+	pairs[1].B = 40
+	tracker.DetectChanges()
+
+	if len(tracker.events) == 0 {
+		t.Fatal("no events detected")
+	}
+	for _, evt := range tracker.events {
+		if evt.Name != "pairs[1].B" {
+			t.Errorf("event at offset %d named %q, want pairs[1].B", evt.Offset, evt.Name)
+		}
+	}
+
+	counts := []uint16{0, 0, 0}
+	WatchSliceOf(tracker, counts, "counts")
+	counts[2] = 0x0101
+	tracker.AssertChanges(t, []MemoryEvent{
+		{Name: "counts[2]", Offset: 4, OldValue: 0, NewValue: 1},
+		{Name: "counts[2]", Offset: 5, OldValue: 0, NewValue: 1},
+	})
+}
+
+func TestWatchSliceOfRejectsInvalidElements(t *testing.T) {
+	tracker := NewMemoryTracker()
+	if _, err := WatchSliceOf(tracker, []struct{ B []byte }{{}}, "bad"); err == nil {
+		t.Error("WatchSliceOf on elements holding a slice succeeded, want error")
+	}
+	if _, err := WatchSliceOf(tracker, []*int{new(int)}, "bad"); err == nil {
+		t.Error("WatchSliceOf on pointers succeeded, want error")
+	}
+	if _, err := WatchSliceOf(tracker, []int32{}, "bad"); err == nil {
+		t.Error("WatchSliceOf on an empty slice succeeded, want error")
+	}
+}
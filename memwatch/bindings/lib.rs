@@ -36,6 +36,8 @@ pub struct StatsC {
     pub mprotect_page_count: u32,
     pub worker_thread_id: u32,
     pub worker_cycles: u64,
+    pub ring_occupancy: u32,
+    pub ring_capacity: u32,
 }
 
 // C function bindings
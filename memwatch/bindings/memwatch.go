@@ -13,33 +13,71 @@ package memwatch
 */
 import "C"
 import (
+    "context"
+    "errors"
     "fmt"
+    "os"
+    "runtime"
+    "sync"
+    "sync/atomic"
+    "time"
     "unsafe"
 )
 
 // ChangeEvent - unified event structure
 type ChangeEvent struct {
-    Seq             uint32
-    TimestampNs     uint64
-    AdapterID       uint32
-    RegionID        uint32
-    VariableName    string
-    Where           Location
-    OldPreview      []byte
-    NewPreview      []byte
-    OldValue        []byte
-    NewValue        []byte
-    StorageKeyOld   string
-    StorageKeyNew   string
-    Metadata        map[string]interface{}
+    Seq             uint32                 `json:"seq"`
+    TimestampNs     uint64                 `json:"timestamp_ns"`
+    AdapterID       uint32                 `json:"adapter_id"`
+    RegionID        uint32                 `json:"region_id"`
+    VariableName    string                 `json:"variable_name"`
+    Where           Location               `json:"where"`
+    OldPreview      []byte                 `json:"old_preview"`
+    NewPreview      []byte                 `json:"new_preview"`
+    OldValue        []byte                 `json:"old_value"`
+    NewValue        []byte                 `json:"new_value"`
+    StorageKeyOld   string                 `json:"storage_key_old"`
+    StorageKeyNew   string                 `json:"storage_key_new"`
+    Metadata        map[string]interface{} `json:"metadata"`
+    // Truncated is set when a preview or value was cut to the limit given
+    // to SetMaxPreviewBytes
+    Truncated       bool                   `json:"truncated"`
 }
 
 // Location - where the change occurred
 type Location struct {
-    File     string
-    Function string
-    Line     uint32
-    FaultIP  uint64
+    File     string `json:"file"`
+    Function string `json:"function"`
+    Line     uint32 `json:"line"`
+    FaultIP  uint64 `json:"fault_ip"`
+}
+
+// Symbolize resolves FaultIP to "function (file:line)" using the Go runtime
+// symbol table, filling in Function and Line when they are empty. Addresses
+// outside Go code, such as faults in native code, resolve to the hex address.
+func (l *Location) Symbolize() (string, error) {
+    if l.FaultIP == 0 {
+        return "", fmt.Errorf("no fault address to symbolize")
+    }
+
+    pc := uintptr(l.FaultIP)
+    fn := runtime.FuncForPC(pc)
+    if fn == nil {
+        return fmt.Sprintf("%#x", l.FaultIP), nil
+    }
+
+    file, line := fn.FileLine(pc)
+    if l.Function == "" {
+        l.Function = fn.Name()
+    }
+    if l.Line == 0 {
+        l.Line = uint32(line)
+    }
+    if l.File == "" {
+        l.File = file
+    }
+
+    return fmt.Sprintf("%s (%s:%d)", fn.Name(), file, line), nil
 }
 
 // Stats - statistics
@@ -53,6 +91,8 @@ type Stats struct {
     MprotectPageCount     uint32
     WorkerThreadID        uint32
     WorkerCycles          uint64
+    RingOccupancy         uint32
+    RingCapacity          uint32
 }
 
 // ChangeEventCallback - callback function type
@@ -62,21 +102,66 @@ var (
     globalCallback ChangeEventCallback
 )
 
+// Watcher is the part of MemWatch most consumers rely on. Code written
+// against it can be tested with memwatchtest.FakeWatcher on machines
+// without the native library.
+type Watcher interface {
+    Watch(data interface{}, name string) (uint32, error)
+    Unwatch(region_id uint32) bool
+    CheckChanges() ([]*ChangeEvent, error)
+    GetStats() (*Stats, error)
+    SetCallback(callback ChangeEventCallback) error
+    Close()
+}
+
+var _ Watcher = (*MemWatch)(nil)
+
 // MemWatch - the main watcher struct
 type MemWatch struct {
+    native         native
+    mu             sync.Mutex
     trackedObjects map[uint32]interface{}
+    regions        map[uint32]WatchedRegion
+    byName         map[string][]uint32
     callback       ChangeEventCallback
+    http           *httpState
+    maxPreview     int
+    minChange      int
+    maxRegions     int
+    nearLimit      float64
+    nearLimitCb    func(current, max int)
+    nearLimitFired bool
+    closed         bool
+    pollDrops      atomic.Uint64
+}
+
+// WatchedRegion - metadata recorded for each watched region
+type WatchedRegion struct {
+    ID     uint32 `json:"id"`
+    Name   string `json:"name"`
+    Size   int    `json:"size"`
+    Mapped bool   `json:"mapped,omitempty"`
+
+    addr uintptr
 }
 
 // NewWatcher creates a new memory watcher
 func NewWatcher() (*MemWatch, error) {
-    result := C.memwatch_init()
+    return newWatcher(cgoNative{})
+}
+
+// newWatcher creates a watcher on top of the given native layer
+func newWatcher(n native) (*MemWatch, error) {
+    result := n.init()
     if result != 0 {
-        return nil, fmt.Errorf("failed to initialize memwatch: %d", result)
+        return nil, newCodeError(ErrInitFailed, "failed to initialize memwatch", result)
     }
     
     return &MemWatch{
+        native:         n,
         trackedObjects: make(map[uint32]interface{}),
+        regions:        make(map[uint32]WatchedRegion),
+        byName:         make(map[string][]uint32),
     }, nil
 }
 
@@ -84,47 +169,276 @@ func NewWatcher() (*MemWatch, error) {
 // addr: memory address
 // size: size in bytes
 // name: variable name
-// Returns region_id
+// Returns region_id. Watching memory that overlaps an already watched
+// region fails with ErrOverlap, and exceeding SetMaxRegions with
+// ErrRegionLimit.
+//
+// data may be a []byte, an []int or a string. WARNING: a string is watched
+// through its backing array. Mutating a Go string is undefined behavior;
+// only watch strings whose memory is changed from outside Go, read-only.
 func (w *MemWatch) Watch(data interface{}, name string) (uint32, error) {
-    var addr uintptr
+    return w.WatchWithAdapter(data, name, 0)
+}
+
+// WatchWithAdapter is Watch for a specific native adapter. Events from the
+// region carry adapterID in ChangeEvent.AdapterID, so regions served by
+// different adapters, e.g. mmap and mprotect, can be told apart. Watch
+// uses adapter 0.
+func (w *MemWatch) WatchWithAdapter(data interface{}, name string, adapterID uint32) (uint32, error) {
+    var addr unsafe.Pointer
     var size int
     
     switch v := data.(type) {
+    case nil:
+        return 0, fmt.Errorf("%w: cannot watch %s", ErrNilData, name)
     case []byte:
+        if v == nil {
+            return 0, fmt.Errorf("%w: cannot watch %s", ErrNilData, name)
+        }
         if len(v) == 0 {
-            return 0, fmt.Errorf("cannot watch empty slice")
+            return 0, ErrEmptySlice
         }
-        addr = uintptr(unsafe.Pointer(&v[0]))
+        addr = unsafe.Pointer(&v[0])
         size = len(v)
     case []int:
+        if v == nil {
+            return 0, fmt.Errorf("%w: cannot watch %s", ErrNilData, name)
+        }
         if len(v) == 0 {
-            return 0, fmt.Errorf("cannot watch empty slice")
+            return 0, ErrEmptySlice
         }
-        addr = uintptr(unsafe.Pointer(&v[0]))
+        addr = unsafe.Pointer(&v[0])
         size = len(v) * 8 // int is typically 8 bytes
+    case string:
+        // Go strings are immutable and writing to their bytes is undefined
+        // behavior. Watching one is only meant for observing memory that
+        // something outside Go mutates, e.g. when hunting for tampering.
+        if len(v) == 0 {
+            return 0, ErrEmptySlice
+        }
+        addr = unsafe.Pointer(unsafe.StringData(v))
+        size = len(v)
     default:
-        return 0, fmt.Errorf("unsupported type: %T", v)
+        return 0, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
     }
     
-    c_name := C.CString(name)
-    defer C.free(unsafe.Pointer(c_name))
+    region_id, err := w.watchLocked(data, addr, size, name, adapterID)
+    if err != nil {
+        return 0, err
+    }
     
-    region_id := C.memwatch_watch(C.uint64_t(addr), C.size_t(size), c_name, nil)
+    w.notifyNearLimit()
+    return region_id, nil
+}
+
+// WatchRaw watches size bytes at ptr, such as a buffer allocated in C,
+// without going through a Go value. It is the primitive Watch builds on
+// once it has resolved its argument to an address and size. The memory
+// must stay valid until the region is unwatched: unlike Watch, nothing
+// keeps it alive.
+func (w *MemWatch) WatchRaw(ptr unsafe.Pointer, size int, name string) (uint32, error) {
+    if ptr == nil {
+        return 0, ErrNilPointer
+    }
+    if size <= 0 {
+        return 0, fmt.Errorf("%w: size %d", ErrEmptySlice, size)
+    }
     
-    if region_id > 0 {
-        w.trackedObjects[uint32(region_id)] = data
+    region_id, err := w.watchLocked(nil, ptr, size, name, 0)
+    if err != nil {
+        return 0, err
     }
     
-    return uint32(region_id), nil
+    w.notifyNearLimit()
+    return region_id, nil
+}
+
+// watchLocked registers a region with the native layer under w.mu
+func (w *MemWatch) watchLocked(data interface{}, addr unsafe.Pointer, size int, name string, adapterID uint32) (uint32, error) {
+    start := uintptr(addr)
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    
+    if w.closed {
+        return 0, ErrClosed
+    }
+    if w.maxRegions > 0 && len(w.regions) >= w.maxRegions {
+        return 0, fmt.Errorf("%w: cannot watch %s, %d of %d regions in use", ErrRegionLimit, name, len(w.regions), w.maxRegions)
+    }
+    
+    for _, region := range w.regions {
+        if start < region.addr+uintptr(region.Size) && region.addr < start+uintptr(size) {
+            return 0, fmt.Errorf("%w: %s overlaps %s", ErrOverlap, name, region.Name)
+        }
+    }
+    
+    region_id, code := w.native.watch(addr, size, name, adapterID)
+    if code != nativeOK {
+        return 0, newCodeError(nativeCategory(code), "cannot watch "+name, code)
+    }
+    
+    w.trackedObjects[region_id] = data
+    w.byName[name] = append(w.byName[name], region_id)
+    w.regions[region_id] = WatchedRegion{ID: region_id, Name: name, Size: size, addr: start}
+    
+    return region_id, nil
+}
+
+// SetMaxRegions caps how many regions may be watched at once, e.g. to stay
+// within the native layer's watchpoint budget. Watch fails with
+// ErrRegionLimit once n regions are watched. n <= 0 removes the cap.
+func (w *MemWatch) SetMaxRegions(n int) {
+    if n < 0 {
+        n = 0
+    }
+    
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.maxRegions = n
+    w.nearLimitFired = false
+}
+
+// OnNearLimit calls cb when a Watch brings usage to at least threshold (a
+// fraction such as 0.8) of the SetMaxRegions cap. It fires once per
+// crossing: usage has to drop below the threshold before it fires again.
+// cb runs on the goroutine calling Watch, outside the watcher's lock.
+func (w *MemWatch) OnNearLimit(threshold float64, cb func(current, max int)) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.nearLimit = threshold
+    w.nearLimitCb = cb
+    w.nearLimitFired = false
+}
+
+// notifyNearLimit runs the OnNearLimit callback if usage has just crossed
+// its threshold
+func (w *MemWatch) notifyNearLimit() {
+    w.mu.Lock()
+    current, max, cb := len(w.regions), w.maxRegions, w.nearLimitCb
+    fire := cb != nil && max > 0 && !w.nearLimitFired && float64(current) >= w.nearLimit*float64(max)
+    if fire {
+        w.nearLimitFired = true
+    }
+    w.mu.Unlock()
+    
+    if fire {
+        cb(current, max)
+    }
+}
+
+// rearmNearLimit lets the OnNearLimit callback fire again once usage is
+// back below its threshold. Called with w.mu held.
+func (w *MemWatch) rearmNearLimit() {
+    if float64(len(w.regions)) < w.nearLimit*float64(w.maxRegions) {
+        w.nearLimitFired = false
+    }
+}
+
+// MmapAdapterID is the native adapter WatchMmap registers regions under,
+// so their events can be told apart from those of other regions
+const MmapAdapterID uint32 = C.MEMWATCH_ADAPTER_MMAP
+
+// WatchMmap watches memory mapped from a file, such as a shared mapping
+// another process writes to. The base of data must be page-aligned, which
+// is always the case for the slice returned by mmap itself; sub-slices
+// starting mid-page are rejected. The region is watched under
+// MmapAdapterID, so its events carry that AdapterID, and is marked Mapped
+// in the watcher's region metadata.
+func (w *MemWatch) WatchMmap(data []byte, name string) (uint32, error) {
+    if len(data) == 0 {
+        return 0, ErrEmptySlice
+    }
+    if addr := uintptr(unsafe.Pointer(&data[0])); addr%uintptr(os.Getpagesize()) != 0 {
+        return 0, fmt.Errorf("mmap region %s at %#x is not page-aligned", name, addr)
+    }
+    
+    region_id, err := w.WatchWithAdapter(data, name, MmapAdapterID)
+    if err != nil {
+        return 0, err
+    }
+    
+    w.mu.Lock()
+    region := w.regions[region_id]
+    region.Mapped = true
+    w.regions[region_id] = region
+    w.mu.Unlock()
+    
+    return region_id, nil
 }
 
 // Unwatch stops watching a region
 func (w *MemWatch) Unwatch(region_id uint32) bool {
-    result := C.memwatch_unwatch(C.memwatch_region_id(region_id))
+    result := w.native.unwatch(region_id)
     if result {
+        w.mu.Lock()
+        w.forgetName(region_id)
         delete(w.trackedObjects, region_id)
+        delete(w.regions, region_id)
+        w.rearmNearLimit()
+        w.mu.Unlock()
+    }
+    return result
+}
+
+// RegionByName returns the id of the watched region called name. When
+// several watched regions share the name, the most recently watched one is
+// returned; once it is unwatched, the next most recent takes its place.
+func (w *MemWatch) RegionByName(name string) (uint32, bool) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    
+    ids := w.byName[name]
+    if len(ids) == 0 {
+        return 0, false
+    }
+    return ids[len(ids)-1], true
+}
+
+// UnwatchByName stops watching the region RegionByName returns for name
+func (w *MemWatch) UnwatchByName(name string) bool {
+    region_id, ok := w.RegionByName(name)
+    if !ok {
+        return false
+    }
+    return w.Unwatch(region_id)
+}
+
+// forgetName drops region_id from the name index. Called with w.mu held.
+func (w *MemWatch) forgetName(region_id uint32) {
+    name := w.regions[region_id].Name
+    ids := w.byName[name]
+    for i, id := range ids {
+        if id == region_id {
+            ids = append(ids[:i:i], ids[i+1:]...)
+            break
+        }
+    }
+    
+    if len(ids) == 0 {
+        delete(w.byName, name)
+    } else {
+        w.byName[name] = ids
+    }
+}
+
+// UnwatchAll stops watching every region while keeping the watcher
+// usable for further Watch calls. Returns the number of regions removed.
+func (w *MemWatch) UnwatchAll() int {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    
+    removed := 0
+    for region_id := range w.trackedObjects {
+        if w.native.unwatch(region_id) {
+            removed++
+        }
     }
-    return bool(result)
+    
+    w.trackedObjects = make(map[uint32]interface{})
+    w.regions = make(map[uint32]WatchedRegion)
+    w.byName = make(map[string][]uint32)
+    w.rearmNearLimit()
+    return removed
 }
 
 // SetCallback sets the change event callback
@@ -133,92 +447,322 @@ func (w *MemWatch) SetCallback(callback ChangeEventCallback) error {
     globalCallback = callback
     
     if callback != nil {
-        // We would need to use cgo callback mechanism here
-        // This is a simplified version
-        result := C.memwatch_set_callback(nil, nil)
+        result := w.native.setCallback(true)
         if result != 0 {
-            return fmt.Errorf("failed to set callback: %d", result)
+            return newCodeError(nil, "failed to set callback", result)
         }
     } else {
-        C.memwatch_set_callback(nil, nil)
+        w.native.setCallback(false)
     }
     
     return nil
 }
 
+// SetMaxPreviewBytes limits the previews and values CheckChanges copies
+// out of each native event to n bytes apiece. Events that lost bytes have
+// Truncated set. 0 means unlimited.
+func (w *MemWatch) SetMaxPreviewBytes(n int) {
+    if n < 0 {
+        n = 0
+    }
+    
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.maxPreview = n
+}
+
+// SetMinChangeBytes makes CheckChanges and CheckChangesInto skip events
+// whose old and new previews differ in fewer than n bytes; bytes one
+// preview has beyond the other's end count as differing. Skipped events
+// are freed natively like any other. 0 keeps every event.
+func (w *MemWatch) SetMinChangeBytes(n int) {
+    if n < 0 {
+        n = 0
+    }
+    
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.minChange = n
+}
+
+// changedBytes counts the bytes that differ between two previews
+func changedBytes(old, cur []byte) int {
+    n := len(old)
+    if len(cur) < n {
+        n = len(cur)
+    }
+    
+    changed := len(old) + len(cur) - 2*n
+    for i := 0; i < n; i++ {
+        if old[i] != cur[i] {
+            changed++
+        }
+    }
+    return changed
+}
+
 // CheckChanges synchronously checks for changes (polling mode)
 func (w *MemWatch) CheckChanges() ([]*ChangeEvent, error) {
+    return w.CheckChangesContext(context.Background())
+}
+
+// CheckChangesContext is CheckChanges with cancellation. ctx is checked
+// between native event reads; if it is cancelled mid-drain the remaining
+// native events are freed and ctx.Err() is returned.
+func (w *MemWatch) CheckChangesContext(ctx context.Context) ([]*ChangeEvent, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    
+    w.mu.Lock()
+    closed := w.closed
+    limit := w.maxPreview
+    minChange := w.minChange
+    w.mu.Unlock()
+    
+    if closed {
+        return nil, ErrClosed
+    }
+    
     const maxEvents = 16
-    events := make([]C.memwatch_change_event_t, maxEvents)
-    
-    count := C.memwatch_check_changes(&events[0], C.int(maxEvents))
-    
-    result := make([]*ChangeEvent, 0, int(count))
-    
-    for i := 0; i < int(count); i++ {
-        evt := &events[i]
-        changeEvent := &ChangeEvent{
-            Seq:          uint32(evt.seq),
-            TimestampNs:  uint64(evt.timestamp_ns),
-            AdapterID:    uint32(evt.adapter_id),
-            RegionID:     uint32(evt.region_id),
-            VariableName: C.GoString(evt.variable_name),
-            Where: Location{
-                File:     C.GoString(evt.file),
-                Function: C.GoString(evt.function),
-                Line:     uint32(evt.line),
-                FaultIP:  uint64(evt.fault_ip),
-            },
-            Metadata: make(map[string]interface{}),
+    events, code := w.native.checkChanges(maxEvents)
+    if code < 0 {
+        return nil, newCodeError(nil, "failed to check changes", code)
+    }
+    
+    result := make([]*ChangeEvent, 0, len(events))
+    
+    for i := range events {
+        select {
+        case <-ctx.Done():
+            for j := i; j < len(events); j++ {
+                w.native.freeEvent(&events[j])
+            }
+            return nil, ctx.Err()
+        default:
         }
         
-        if evt.old_preview_size > 0 && evt.old_preview != nil {
-            changeEvent.OldPreview = C.GoBytes(unsafe.Pointer(evt.old_preview), C.int(evt.old_preview_size))
+        if minChange > 0 && changedBytes(events[i].oldPreview, events[i].newPreview) < minChange {
+            w.native.freeEvent(&events[i])
+            continue
         }
+        result = append(result, w.convertEvent(&events[i], limit))
+    }
+    
+    return result, nil
+}
+
+// pollBuffer is how many events StartPolling buffers for a slow consumer
+const pollBuffer = 256
+
+// StartPolling calls CheckChanges every interval on a new goroutine and
+// delivers the events and errors on the returned channels until ctx is
+// cancelled or the watcher is closed, then closes both. Events that find
+// the buffered event channel full are dropped and counted by PollDrops;
+// errors are dropped likewise if an earlier one is still unread.
+func (w *MemWatch) StartPolling(ctx context.Context, interval time.Duration) (<-chan *ChangeEvent, <-chan error) {
+    events := make(chan *ChangeEvent, pollBuffer)
+    errs := make(chan error, 1)
+    
+    go func() {
+        defer close(events)
+        defer close(errs)
         
-        if evt.new_preview_size > 0 && evt.new_preview != nil {
-            changeEvent.NewPreview = C.GoBytes(unsafe.Pointer(evt.new_preview), C.int(evt.new_preview_size))
-        }
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
         
-        C.memwatch_free_event(evt)
-        result = append(result, changeEvent)
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+            }
+            
+            batch, err := w.CheckChangesContext(ctx)
+            if ctx.Err() != nil {
+                return
+            }
+            if err != nil {
+                select {
+                case errs <- err:
+                default:
+                }
+                if errors.Is(err, ErrClosed) {
+                    return
+                }
+                continue
+            }
+            
+            for _, evt := range batch {
+                select {
+                case events <- evt:
+                default:
+                    w.pollDrops.Add(1)
+                }
+            }
+        }
+    }()
+    
+    return events, errs
+}
+
+// PollDrops returns how many events StartPolling dropped because its
+// consumer fell behind
+func (w *MemWatch) PollDrops() uint64 {
+    return w.pollDrops.Load()
+}
+
+// CheckChangesInto is CheckChanges without per-event allocations. It
+// fills the ChangeEvents of buf, allocating only for nil entries, with up
+// to len(buf) pending events and returns how many it filled. Previews and
+// values are copied back to back into scratch; what does not fit is cut
+// and marks the event Truncated. The returned byte slices alias scratch
+// and are only valid until the next call that reuses it.
+func (w *MemWatch) CheckChangesInto(buf []*ChangeEvent, scratch []byte) (int, error) {
+    w.mu.Lock()
+    closed := w.closed
+    limit := w.maxPreview
+    minChange := w.minChange
+    w.mu.Unlock()
+    
+    if closed {
+        return 0, ErrClosed
+    }
+    if len(buf) == 0 {
+        return 0, nil
     }
     
-    return result, nil
+    events, code := w.native.checkChanges(len(buf))
+    if code < 0 {
+        return 0, newCodeError(nil, "failed to check changes", code)
+    }
+    
+    n := 0
+    for i := range events {
+        if minChange > 0 && changedBytes(events[i].oldPreview, events[i].newPreview) < minChange {
+            w.native.freeEvent(&events[i])
+            continue
+        }
+        if buf[n] == nil {
+            buf[n] = &ChangeEvent{}
+        }
+        if buf[n].Metadata == nil {
+            buf[n].Metadata = make(map[string]interface{})
+        }
+        for key := range buf[n].Metadata {
+            delete(buf[n].Metadata, key)
+        }
+        w.fillEvent(buf[n], &events[i], limit, &scratch)
+        n++
+    }
+    
+    return n, nil
+}
+
+// convertEvent copies a native event into Go memory and frees it. Previews
+// and values are cut to limit bytes unless limit is 0.
+func (w *MemWatch) convertEvent(evt *rawEvent, limit int) *ChangeEvent {
+    changeEvent := &ChangeEvent{Metadata: make(map[string]interface{})}
+    w.fillEvent(changeEvent, evt, limit, nil)
+    return changeEvent
+}
+
+// fillEvent copies a native event into dst, keeping dst's Metadata, and
+// frees it. Previews and values are cut to limit bytes unless limit is 0.
+// With a nil arena they are copied to fresh slices; otherwise they are
+// carved from the front of *arena, cut to what is left of it.
+func (w *MemWatch) fillEvent(dst *ChangeEvent, evt *rawEvent, limit int, arena *[]byte) {
+    truncated := false
+    clip := func(b []byte) []byte {
+        if limit > 0 && len(b) > limit {
+            truncated = true
+            b = b[:limit]
+        }
+        if arena == nil {
+            return copyBytes(b)
+        }
+        if len(b) > len(*arena) {
+            truncated = true
+            b = b[:len(*arena)]
+        }
+        if len(b) == 0 {
+            return nil
+        }
+        out := (*arena)[:len(b):len(b)]
+        *arena = (*arena)[len(b):]
+        copy(out, b)
+        return out
+    }
+    
+    *dst = ChangeEvent{
+        Seq:          evt.seq,
+        TimestampNs:  evt.timestampNs,
+        AdapterID:    evt.adapterID,
+        RegionID:     evt.regionID,
+        VariableName: evt.variableName,
+        Where: Location{
+            File:     evt.file,
+            Function: evt.function,
+            Line:     evt.line,
+            FaultIP:  evt.faultIP,
+        },
+        OldPreview:    clip(evt.oldPreview),
+        NewPreview:    clip(evt.newPreview),
+        OldValue:      clip(evt.oldValue),
+        NewValue:      clip(evt.newValue),
+        StorageKeyOld: evt.storageKeyOld,
+        StorageKeyNew: evt.storageKeyNew,
+        Metadata:      dst.Metadata,
+    }
+    dst.Truncated = truncated
+    
+    if dst.Where.Function == "" && dst.Where.FaultIP != 0 {
+        dst.Where.Symbolize()
+    }
+    
+    w.native.freeEvent(evt)
+}
+
+// copyBytes copies native bytes into Go memory, keeping nil for empty input
+func copyBytes(b []byte) []byte {
+    if len(b) == 0 {
+        return nil
+    }
+    return append([]byte(nil), b...)
 }
 
 // GetStats returns current statistics
 func (w *MemWatch) GetStats() (*Stats, error) {
-    var c_stats C.memwatch_stats_t
-    result := C.memwatch_get_stats(&c_stats)
+    stats, result := w.native.getStats()
     
     if result != 0 {
-        return nil, fmt.Errorf("failed to get stats: %d", result)
-    }
-    
-    return &Stats{
-        NumTrackedRegions:    uint32(c_stats.num_tracked_regions),
-        NumActiveWatchpoints: uint32(c_stats.num_active_watchpoints),
-        TotalEvents:          uint64(c_stats.total_events),
-        RingWriteCount:       uint64(c_stats.ring_write_count),
-        RingDropCount:        uint64(c_stats.ring_drop_count),
-        StorageBytesUsed:     uint64(c_stats.storage_bytes_used),
-        MprotectPageCount:    uint32(c_stats.mprotect_page_count),
-        WorkerThreadID:       uint32(c_stats.worker_thread_id),
-        WorkerCycles:         uint64(c_stats.worker_cycles),
-    }, nil
+        return nil, newCodeError(nil, "failed to get stats", result)
+    }
+    
+    return &stats, nil
 }
 
-// Close shuts down the watcher
+// Close shuts down the watcher. Only the first call shuts the native layer
+// down; later calls are no-ops, so an explicit Close may be combined with a
+// deferred one.
 func (w *MemWatch) Close() {
-    C.memwatch_shutdown()
+    w.mu.Lock()
+    if w.closed {
+        w.mu.Unlock()
+        return
+    }
+    w.closed = true
+    w.mu.Unlock()
+    
+    w.stopHTTP()
+    w.native.shutdown()
 }
 
 // Legacy functions for backwards compatibility
 func Init() error {
     result := C.memwatch_init()
     if result != 0 {
-        return fmt.Errorf("failed to initialize: %d", result)
+        return newCodeError(ErrInitFailed, "failed to initialize", int(result))
     }
     return nil
 }
@@ -0,0 +1,75 @@
+package memwatch
+
+import (
+    "errors"
+    "fmt"
+)
+
+// Error categories returned by MemWatch operations. Match them with
+// errors.Is; failures reported by the native layer additionally carry its
+// return code as a *CodeError.
+var (
+    // ErrInitFailed means memwatch_init returned an error
+    ErrInitFailed = errors.New("memwatch initialization failed")
+    // ErrNotInitialized means the native layer refused a request because
+    // it is not initialized, e.g. after the legacy Shutdown
+    ErrNotInitialized = errors.New("memwatch not initialized")
+    // ErrClosed means the watcher was used after Close
+    ErrClosed = errors.New("memwatch closed")
+    // ErrRegionLimit means Watch would exceed the SetMaxRegions cap
+    ErrRegionLimit = errors.New("region limit reached")
+    // ErrOverlap means the memory overlaps a region that is already watched
+    ErrOverlap = errors.New("region overlaps a watched region")
+    // ErrUnsupportedType means Watch was given a type it cannot watch
+    ErrUnsupportedType = errors.New("unsupported type")
+    // ErrNilPointer means WatchRaw was given a nil address
+    ErrNilPointer = errors.New("cannot watch nil pointer")
+    // ErrNilData means Watch was given a nil slice, or no value at all
+    ErrNilData = errors.New("cannot watch nil data")
+    // ErrEmptySlice means Watch was given zero bytes to watch
+    ErrEmptySlice = errors.New("cannot watch empty slice")
+    // ErrInvalidRegion means the native layer rejected the address or size
+    // of a region
+    ErrInvalidRegion = errors.New("invalid region")
+    // ErrNative means the native layer failed for a reason of its own, such
+    // as running out of memory or region slots
+    ErrNative = errors.New("native memwatch error")
+)
+
+// CodeError is a failure reported by the native layer together with the
+// return code it reported
+type CodeError struct {
+    msg  string
+    code int
+    err  error
+}
+
+// newCodeError describes a native failure; category may be nil
+func newCodeError(category error, msg string, code int) *CodeError {
+    return &CodeError{msg: msg, code: code, err: category}
+}
+
+// nativeCategory maps a native return code to its error category
+func nativeCategory(code int) error {
+    switch code {
+    case nativeErrNotInit:
+        return ErrNotInitialized
+    case nativeErrInvalidAddr:
+        return ErrInvalidRegion
+    }
+    return ErrNative
+}
+
+func (e *CodeError) Error() string {
+    return fmt.Sprintf("%s: %d", e.msg, e.code)
+}
+
+// Code returns the native return code
+func (e *CodeError) Code() int {
+    return e.code
+}
+
+// Unwrap returns the error category, if any
+func (e *CodeError) Unwrap() error {
+    return e.err
+}
@@ -0,0 +1,35 @@
+package memwatch
+
+import (
+    "encoding/gob"
+    "io"
+)
+
+func init() {
+    // Metadata values travel as interface{}; gob needs the composite
+    // types registered up front (basic types are built in).
+    gob.Register(map[string]interface{}{})
+    gob.Register([]interface{}{})
+}
+
+// EncodeEvents writes events to w in gob format
+func EncodeEvents(w io.Writer, events []*ChangeEvent) error {
+    return gob.NewEncoder(w).Encode(events)
+}
+
+// DecodeEvents reads events written by EncodeEvents
+func DecodeEvents(r io.Reader) ([]*ChangeEvent, error) {
+    var events []*ChangeEvent
+    if err := gob.NewDecoder(r).Decode(&events); err != nil {
+        return nil, err
+    }
+    
+    // gob omits empty maps; restore the non-nil Metadata CheckChanges provides
+    for _, evt := range events {
+        if evt.Metadata == nil {
+            evt.Metadata = make(map[string]interface{})
+        }
+    }
+    
+    return events, nil
+}
@@ -0,0 +1,266 @@
+package memwatch
+
+import (
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+)
+
+const (
+    // httpEventBufferSize bounds how many recent events /events can return
+    httpEventBufferSize = 1024
+    // httpPollInterval is how often the background drain calls CheckChanges
+    httpPollInterval = 10 * time.Millisecond
+    // streamSubscriberBuffer is how many events a slow SSE client may lag
+    // behind before further events are dropped for it
+    streamSubscriberBuffer = 64
+)
+
+// httpState holds the HTTP servers and the event buffer they serve from
+type httpState struct {
+    servers []*http.Server
+    stop    chan struct{}
+    done    chan struct{}
+
+    mu          sync.Mutex
+    events      []*ChangeEvent
+    subscribers map[chan *ChangeEvent]struct{}
+}
+
+// ServeHTTP starts a debugging HTTP server on addr exposing:
+//   /events   the most recent change events as JSON (?n= limits the count)
+//   /stats    the GetStats output as JSON
+//   /regions  metadata of the currently watched regions as JSON
+//   /events/stream  live change events as Server-Sent Events
+// A background goroutine drains CheckChanges into a bounded buffer, so
+// events served here are no longer returned by direct CheckChanges calls.
+// The server is shut down by Close; after Close, ServeHTTP fails with
+// ErrClosed.
+func (w *MemWatch) ServeHTTP(addr string) (*http.Server, error) {
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+        return nil, err
+    }
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/events", w.handleEvents)
+    mux.HandleFunc("/stats", w.handleStats)
+    mux.HandleFunc("/regions", w.handleRegions)
+    mux.Handle("/events/stream", w.StreamHandler())
+
+    srv := &http.Server{Addr: ln.Addr().String(), Handler: mux}
+
+    w.mu.Lock()
+    state, err := w.httpStateLocked()
+    if err != nil {
+        w.mu.Unlock()
+        ln.Close()
+        return nil, err
+    }
+    state.servers = append(state.servers, srv)
+    w.mu.Unlock()
+
+    go srv.Serve(ln)
+
+    return srv, nil
+}
+
+// httpStateLocked returns the HTTP state, starting the background drain on
+// first use. A closed watcher gets ErrClosed instead, as a drain started
+// then would poll a shut-down watcher forever. w.mu must be held.
+func (w *MemWatch) httpStateLocked() (*httpState, error) {
+    if w.closed {
+        return nil, ErrClosed
+    }
+    if w.http == nil {
+        w.http = &httpState{
+            stop:        make(chan struct{}),
+            done:        make(chan struct{}),
+            subscribers: make(map[chan *ChangeEvent]struct{}),
+        }
+        go w.drainEvents(w.http)
+    }
+    return w.http, nil
+}
+
+// drainEvents polls CheckChanges into the HTTP event buffer until stopped
+func (w *MemWatch) drainEvents(state *httpState) {
+    defer close(state.done)
+
+    ticker := time.NewTicker(httpPollInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-state.stop:
+            return
+        case <-ticker.C:
+        }
+
+        events, err := w.CheckChanges()
+        if err != nil || len(events) == 0 {
+            continue
+        }
+
+        state.mu.Lock()
+        state.events = append(state.events, events...)
+        if over := len(state.events) - httpEventBufferSize; over > 0 {
+            state.events = append(state.events[:0:0], state.events[over:]...)
+        }
+        for ch := range state.subscribers {
+            for _, evt := range events {
+                select {
+                case ch <- evt:
+                default:
+                }
+            }
+        }
+        state.mu.Unlock()
+    }
+}
+
+// stopHTTP shuts down all servers started by ServeHTTP
+func (w *MemWatch) stopHTTP() {
+    w.mu.Lock()
+    state := w.http
+    w.http = nil
+    w.mu.Unlock()
+
+    if state == nil {
+        return
+    }
+
+    for _, srv := range state.servers {
+        srv.Close()
+    }
+    close(state.stop)
+    <-state.done
+
+    state.mu.Lock()
+    for ch := range state.subscribers {
+        delete(state.subscribers, ch)
+        close(ch)
+    }
+    state.mu.Unlock()
+}
+
+// StreamHandler returns a handler that streams change events to each client
+// as Server-Sent Events, one "data: <json>" frame per event. Every client
+// gets its own subscription, removed when the client disconnects. Once the
+// watcher is closed, clients get 503 Service Unavailable.
+func (w *MemWatch) StreamHandler() http.Handler {
+    return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+        flusher, ok := rw.(http.Flusher)
+        if !ok {
+            http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+            return
+        }
+
+        ch, unsubscribe, err := w.subscribe()
+        if err != nil {
+            http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+            return
+        }
+        defer unsubscribe()
+
+        rw.Header().Set("Content-Type", "text/event-stream")
+        rw.Header().Set("Cache-Control", "no-cache")
+        rw.Header().Set("Connection", "keep-alive")
+        rw.WriteHeader(http.StatusOK)
+        flusher.Flush()
+
+        for {
+            select {
+            case <-r.Context().Done():
+                return
+            case evt, ok := <-ch:
+                if !ok {
+                    return
+                }
+                data, err := json.Marshal(evt)
+                if err != nil {
+                    continue
+                }
+                fmt.Fprintf(rw, "data: %s\n\n", data)
+                flusher.Flush()
+            }
+        }
+    })
+}
+
+// subscribe registers a channel receiving every drained event. It fails
+// with ErrClosed once the watcher is closed.
+func (w *MemWatch) subscribe() (chan *ChangeEvent, func(), error) {
+    w.mu.Lock()
+    state, err := w.httpStateLocked()
+    w.mu.Unlock()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    ch := make(chan *ChangeEvent, streamSubscriberBuffer)
+    state.mu.Lock()
+    state.subscribers[ch] = struct{}{}
+    state.mu.Unlock()
+
+    return ch, func() {
+        state.mu.Lock()
+        defer state.mu.Unlock()
+        if _, ok := state.subscribers[ch]; ok {
+            delete(state.subscribers, ch)
+            close(ch)
+        }
+    }, nil
+}
+
+func (w *MemWatch) handleEvents(rw http.ResponseWriter, r *http.Request) {
+    w.mu.Lock()
+    state := w.http
+    w.mu.Unlock()
+
+    events := []*ChangeEvent{}
+    if state != nil {
+        state.mu.Lock()
+        events = append(events, state.events...)
+        state.mu.Unlock()
+    }
+
+    if n, err := strconv.Atoi(r.URL.Query().Get("n")); err == nil && n >= 0 && n < len(events) {
+        events = events[len(events)-n:]
+    }
+
+    writeJSON(rw, events)
+}
+
+func (w *MemWatch) handleStats(rw http.ResponseWriter, r *http.Request) {
+    stats, err := w.GetStats()
+    if err != nil {
+        http.Error(rw, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    writeJSON(rw, stats)
+}
+
+func (w *MemWatch) handleRegions(rw http.ResponseWriter, r *http.Request) {
+    w.mu.Lock()
+    regions := make([]WatchedRegion, 0, len(w.regions))
+    for _, region := range w.regions {
+        regions = append(regions, region)
+    }
+    w.mu.Unlock()
+
+    sort.Slice(regions, func(i, j int) bool { return regions[i].ID < regions[j].ID })
+    writeJSON(rw, regions)
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+    rw.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(rw).Encode(v); err != nil {
+        http.Error(rw, err.Error(), http.StatusInternalServerError)
+    }
+}
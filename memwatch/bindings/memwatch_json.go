@@ -0,0 +1,204 @@
+package memwatch
+
+import (
+    "bytes"
+    "encoding/json"
+    "reflect"
+    "sort"
+    "strings"
+)
+
+// NamingStyle selects how MarshalEvents spells JSON object keys
+type NamingStyle int
+
+const (
+    // SnakeCase keeps the snake_case keys of the json struct tags
+    SnakeCase NamingStyle = iota
+    // CamelCase rewrites keys such as old_value to oldValue
+    CamelCase
+)
+
+// MarshalEvents encodes events, typically a slice of ChangeEvent, with keys
+// in the given style. It works on any value whose types carry snake_case
+// json tags, including the MemoryEvent and SQLChange types of the sibling
+// packages. Only keys coming from struct fields are restyled; keys of maps
+// such as Metadata are user data and kept as they are.
+func MarshalEvents(events interface{}, style NamingStyle) ([]byte, error) {
+    if style == SnakeCase {
+        return json.Marshal(events)
+    }
+    
+    var buf bytes.Buffer
+    if err := writeStyled(&buf, reflect.ValueOf(events)); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// writeStyled writes v as JSON with the keys of struct objects in
+// camelCase. Structs are encoded by encoding/json first, so tags,
+// omitempty and custom MarshalJSON methods all apply; only the keys of the
+// resulting object are renamed, descending into fields that hold structs.
+func writeStyled(buf *bytes.Buffer, v reflect.Value) error {
+    for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+        if v.IsNil() {
+            buf.WriteString("null")
+            return nil
+        }
+        v = v.Elem()
+    }
+    if !v.IsValid() {
+        buf.WriteString("null")
+        return nil
+    }
+    
+    switch v.Kind() {
+    case reflect.Struct:
+        return writeStyledStruct(buf, v)
+    case reflect.Slice, reflect.Array:
+        if v.Type().Elem().Kind() == reflect.Uint8 || (v.Kind() == reflect.Slice && v.IsNil()) {
+            return appendJSON(buf, v)
+        }
+        buf.WriteByte('[')
+        for i := 0; i < v.Len(); i++ {
+            if i > 0 {
+                buf.WriteByte(',')
+            }
+            if err := writeStyled(buf, v.Index(i)); err != nil {
+                return err
+            }
+        }
+        buf.WriteByte(']')
+        return nil
+    case reflect.Map:
+        if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+            return appendJSON(buf, v)
+        }
+        // Sorted like encoding/json does, for stable output
+        keys := v.MapKeys()
+        sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+        
+        buf.WriteByte('{')
+        for i, key := range keys {
+            if i > 0 {
+                buf.WriteByte(',')
+            }
+            if err := appendJSON(buf, reflect.ValueOf(key.String())); err != nil {
+                return err
+            }
+            buf.WriteByte(':')
+            if err := writeStyled(buf, v.MapIndex(key)); err != nil {
+                return err
+            }
+        }
+        buf.WriteByte('}')
+        return nil
+    default:
+        return appendJSON(buf, v)
+    }
+}
+
+// writeStyledStruct encodes v with encoding/json and rewrites the keys of
+// the object it produces
+func writeStyledStruct(buf *bytes.Buffer, v reflect.Value) error {
+    data, err := json.Marshal(v.Interface())
+    if err != nil {
+        return err
+    }
+    if len(data) == 0 || data[0] != '{' {
+        // e.g. time.Time, which encodes as a string
+        buf.Write(data)
+        return nil
+    }
+    
+    fields := jsonFields(v)
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.Token() // {
+    
+    buf.WriteByte('{')
+    for first := true; dec.More(); first = false {
+        tok, err := dec.Token()
+        if err != nil {
+            return err
+        }
+        key := tok.(string)
+        var raw json.RawMessage
+        if err := dec.Decode(&raw); err != nil {
+            return err
+        }
+        
+        if !first {
+            buf.WriteByte(',')
+        }
+        if err := appendJSON(buf, reflect.ValueOf(camelCase(key))); err != nil {
+            return err
+        }
+        buf.WriteByte(':')
+        
+        if field, ok := fields[key]; ok && holdsStructs(field.Type()) {
+            if err := writeStyled(buf, field); err != nil {
+                return err
+            }
+        } else {
+            buf.Write(raw)
+        }
+    }
+    buf.WriteByte('}')
+    return nil
+}
+
+// jsonFields maps the JSON key of each exported field of struct v to the
+// field's value
+func jsonFields(v reflect.Value) map[string]reflect.Value {
+    fields := make(map[string]reflect.Value)
+    t := v.Type()
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        if !f.IsExported() {
+            continue
+        }
+        name := f.Name
+        if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+            continue
+        } else if tag != "" {
+            name = tag
+        }
+        fields[name] = v.Field(i)
+    }
+    return fields
+}
+
+// holdsStructs reports whether values of t contain structs whose keys
+// need restyling
+func holdsStructs(t reflect.Type) bool {
+    for {
+        switch t.Kind() {
+        case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+            t = t.Elem()
+        case reflect.Interface, reflect.Struct:
+            return true
+        default:
+            return false
+        }
+    }
+}
+
+func appendJSON(buf *bytes.Buffer, v reflect.Value) error {
+    data, err := json.Marshal(v.Interface())
+    if err != nil {
+        return err
+    }
+    buf.Write(data)
+    return nil
+}
+
+// camelCase converts a snake_case key such as old_value to oldValue
+func camelCase(key string) string {
+    parts := strings.Split(key, "_")
+    for i := 1; i < len(parts); i++ {
+        if parts[i] != "" {
+            parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+        }
+    }
+    return strings.Join(parts, "")
+}
@@ -0,0 +1,57 @@
+//go:build unix && cgo
+
+package memwatch
+
+import (
+    "os"
+    "path/filepath"
+    "syscall"
+    "testing"
+)
+
+func TestWatchMmapSeesFileWrites(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    path := filepath.Join(t.TempDir(), "mapped.bin")
+    size := os.Getpagesize()
+    if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+        t.Fatal(err)
+    }
+    f, err := os.OpenFile(path, os.O_RDWR, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    
+    data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+    if err != nil {
+        t.Fatalf("mmap: %v", err)
+    }
+    defer syscall.Munmap(data)
+    
+    if _, err := w.WatchMmap(data[1:], "unaligned"); err == nil {
+        t.Error("WatchMmap accepted a region that is not page-aligned")
+    }
+    
+    id, err := w.WatchMmap(data, "mapped")
+    if err != nil || id == 0 {
+        t.Fatalf("WatchMmap = %d, %v", id, err)
+    }
+    if !w.regions[id].Mapped {
+        t.Error("region not marked Mapped")
+    }
+    
+    data[42] = 0x7F
+    
+    events, err := w.CheckChanges()
+    if err != nil || len(events) != 1 {
+        t.Fatalf("CheckChanges = %d events, %v, want 1", len(events), err)
+    }
+    if events[0].RegionID != id || events[0].VariableName != "mapped" {
+        t.Errorf("event = region %d %q, want region %d \"mapped\"", events[0].RegionID, events[0].VariableName, id)
+    }
+    if events[0].AdapterID != MmapAdapterID {
+        t.Errorf("event AdapterID = %d, want MmapAdapterID %d", events[0].AdapterID, MmapAdapterID)
+    }
+}
@@ -0,0 +1,173 @@
+package memwatch
+
+/*
+#cgo CFLAGS: -I../include
+#cgo LDFLAGS: -L../build -lmemwatch_core
+
+#include <memwatch_unified.h>
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+    "unsafe"
+)
+
+// Native return codes, from memwatch_unified.h
+const (
+    nativeOK             = C.MEMWATCH_OK
+    nativeErrNotInit     = C.MEMWATCH_ERR_NOT_INIT
+    nativeErrInvalidAddr = C.MEMWATCH_ERR_INVALID_ADDR
+    nativeErrNoMemory    = C.MEMWATCH_ERR_NO_MEMORY
+)
+
+// rawEvent is a native change event as handed over by memwatch_check_changes.
+// The byte slices alias native memory and are only valid until freeEvent.
+type rawEvent struct {
+    seq           uint32
+    timestampNs   uint64
+    adapterID     uint32
+    regionID      uint32
+    variableName  string
+    file          string
+    function      string
+    line          uint32
+    faultIP       uint64
+    oldPreview    []byte
+    newPreview    []byte
+    oldValue      []byte
+    newValue      []byte
+    storageKeyOld string
+    storageKeyNew string
+
+    handle unsafe.Pointer // *C.memwatch_change_event_t
+}
+
+// native is the set of memwatch_* entry points MemWatch relies on. The cgo
+// implementation is used by NewWatcher; tests substitute a pure-Go fake.
+type native interface {
+    init() int
+    shutdown()
+    watch(ptr unsafe.Pointer, size int, name string, adapterID uint32) (uint32, int)
+    unwatch(regionID uint32) bool
+    setCallback(enabled bool) int
+    checkChanges(max int) ([]rawEvent, int)
+    freeEvent(evt *rawEvent)
+    getStats() (Stats, int)
+}
+
+// cMalloc and cFree manage native memory for tests, which cannot use cgo
+// themselves
+func cMalloc(size int) unsafe.Pointer {
+    return C.malloc(C.size_t(size))
+}
+
+func cFree(ptr unsafe.Pointer) {
+    C.free(ptr)
+}
+
+// cgoNative calls straight into libmemwatch_core
+type cgoNative struct{}
+
+func (cgoNative) init() int {
+    return int(C.memwatch_init())
+}
+
+func (cgoNative) shutdown() {
+    C.memwatch_shutdown()
+}
+
+// watch returns the new region's id and the native return code, which is
+// negative on failure
+func (cgoNative) watch(ptr unsafe.Pointer, size int, name string, adapterID uint32) (uint32, int) {
+    c_name := C.CString(name)
+    defer C.free(unsafe.Pointer(c_name))
+
+    var region_id C.memwatch_region_id
+    code := C.memwatch_watch_status(C.uint64_t(uintptr(ptr)), C.size_t(size), c_name,
+        C.memwatch_adapter_id(adapterID), nil, &region_id)
+    return uint32(region_id), int(code)
+}
+
+func (cgoNative) unwatch(regionID uint32) bool {
+    return bool(C.memwatch_unwatch(C.memwatch_region_id(regionID)))
+}
+
+func (cgoNative) setCallback(enabled bool) int {
+    // We would need to use cgo callback mechanism here
+    // This is a simplified version
+    return int(C.memwatch_set_callback(nil, nil))
+}
+
+// checkChanges returns the pending events and the native return code,
+// which is negative on failure
+func (cgoNative) checkChanges(max int) ([]rawEvent, int) {
+    if max <= 0 {
+        return nil, 0
+    }
+
+    events := make([]C.memwatch_change_event_t, max)
+    count := int(C.memwatch_check_changes(&events[0], C.int(max)))
+    if count < 0 {
+        return nil, count
+    }
+
+    result := make([]rawEvent, count)
+    for i := 0; i < count; i++ {
+        evt := &events[i]
+        result[i] = rawEvent{
+            seq:           uint32(evt.seq),
+            timestampNs:   uint64(evt.timestamp_ns),
+            adapterID:     uint32(evt.adapter_id),
+            regionID:      uint32(evt.region_id),
+            variableName:  C.GoString(evt.variable_name),
+            file:          C.GoString(evt.file),
+            function:      C.GoString(evt.function),
+            line:          uint32(evt.line),
+            faultIP:       uint64(evt.fault_ip),
+            oldPreview:    nativeBytes(evt.old_preview, evt.old_preview_size),
+            newPreview:    nativeBytes(evt.new_preview, evt.new_preview_size),
+            oldValue:      nativeBytes(evt.old_value, evt.old_value_size),
+            newValue:      nativeBytes(evt.new_value, evt.new_value_size),
+            storageKeyOld: C.GoString(evt.storage_key_old),
+            storageKeyNew: C.GoString(evt.storage_key_new),
+            handle:        unsafe.Pointer(evt),
+        }
+    }
+
+    return result, count
+}
+
+func (cgoNative) freeEvent(evt *rawEvent) {
+    if evt.handle != nil {
+        C.memwatch_free_event((*C.memwatch_change_event_t)(evt.handle))
+        evt.handle = nil
+    }
+}
+
+func (cgoNative) getStats() (Stats, int) {
+    var c_stats C.memwatch_stats_t
+    result := C.memwatch_get_stats(&c_stats)
+
+    return Stats{
+        NumTrackedRegions:    uint32(c_stats.num_tracked_regions),
+        NumActiveWatchpoints: uint32(c_stats.num_active_watchpoints),
+        TotalEvents:          uint64(c_stats.total_events),
+        RingWriteCount:       uint64(c_stats.ring_write_count),
+        RingDropCount:        uint64(c_stats.ring_drop_count),
+        StorageBytesUsed:     uint64(c_stats.storage_bytes_used),
+        MprotectPageCount:    uint32(c_stats.mprotect_page_count),
+        WorkerThreadID:       uint32(c_stats.worker_thread_id),
+        WorkerCycles:         uint64(c_stats.worker_cycles),
+        RingOccupancy:        uint32(c_stats.ring_occupancy),
+        RingCapacity:         uint32(c_stats.ring_capacity),
+    }, int(result)
+}
+
+// nativeBytes views a native buffer without copying it
+func nativeBytes(p *C.uint8_t, size C.size_t) []byte {
+    if p == nil || size == 0 {
+        return nil
+    }
+    return unsafe.Slice((*byte)(unsafe.Pointer(p)), int(size))
+}
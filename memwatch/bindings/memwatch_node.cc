@@ -1,3 +1,8 @@
+//go:build ignore
+
+// Node.js addon source, not part of the Go package in this directory; the
+// constraint keeps go build from compiling it with cgo.
+
 /*
  * bindings/memwatch_node.cc - Node.js native binding using N-API
  * 
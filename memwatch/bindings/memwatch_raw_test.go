@@ -0,0 +1,44 @@
+//go:build cgo
+
+package memwatch
+
+import (
+    "errors"
+    "testing"
+    "unsafe"
+)
+
+func TestWatchRawDetectsNativeWrite(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    const size = 64
+    ptr := cMalloc(size)
+    defer cFree(ptr)
+    buf := unsafe.Slice((*byte)(ptr), size)
+    for i := range buf {
+        buf[i] = 0
+    }
+    
+    id, err := w.WatchRaw(ptr, size, "c_buffer")
+    if err != nil {
+        t.Fatalf("WatchRaw: %v", err)
+    }
+    defer w.Unwatch(id)
+    
+    buf[10] = 0x7F
+    events, err := w.CheckChanges()
+    if err != nil {
+        t.Fatalf("CheckChanges: %v", err)
+    }
+    if len(events) != 1 || events[0].RegionID != id || events[0].VariableName != "c_buffer" {
+        t.Fatalf("events = %+v, want one change in c_buffer", events)
+    }
+    
+    if _, err := w.WatchRaw(nil, size, "nil"); !errors.Is(err, ErrNilPointer) {
+        t.Errorf("WatchRaw(nil) error = %v, want ErrNilPointer", err)
+    }
+    if _, err := w.WatchRaw(ptr, 0, "empty"); !errors.Is(err, ErrEmptySlice) {
+        t.Errorf("WatchRaw(size 0) error = %v, want ErrEmptySlice", err)
+    }
+}
@@ -0,0 +1,143 @@
+package memwatch
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "sort"
+    "strings"
+    "time"
+)
+
+// StatsDelta is the growth of each Stats counter between two snapshots.
+// NumTrackedRegions, NumActiveWatchpoints, StorageBytesUsed and
+// MprotectPageCount are gauges rather than counters and hold the value of
+// the newer snapshot.
+type StatsDelta struct {
+    NumTrackedRegions     uint64
+    NumActiveWatchpoints  uint64
+    TotalEvents           uint64
+    RingWriteCount        uint64
+    RingDropCount         uint64
+    StorageBytesUsed      uint64
+    MprotectPageCount     uint64
+    WorkerCycles          uint64
+}
+
+// StatsRate is a StatsDelta expressed per second. The gauges are carried
+// over unchanged, as a rate of a current level means nothing.
+type StatsRate struct {
+    NumTrackedRegions     float64
+    NumActiveWatchpoints  float64
+    TotalEvents           float64
+    RingWriteCount        float64
+    RingDropCount         float64
+    StorageBytesUsed      float64
+    MprotectPageCount     float64
+    WorkerCycles          float64
+}
+
+// RingUsage returns how full the native event ring is, from 0 to 1, so
+// producers can back off before RingDropCount starts to grow. It is 0 when
+// the native layer does not report a RingCapacity.
+func (s *Stats) RingUsage() float64 {
+    if s.RingCapacity == 0 {
+        return 0
+    }
+    return float64(s.RingOccupancy) / float64(s.RingCapacity)
+}
+
+// Sub returns how much each counter grew since prev. A counter that went
+// backwards, because it wrapped or the native layer was restarted, yields
+// a zero delta. Gauges can fall as well as rise, so they are not diffed:
+// the delta holds their current value. WorkerThreadID is an identifier and
+// the ring fill level a gauge, so neither is carried.
+func (s *Stats) Sub(prev *Stats) StatsDelta {
+    return StatsDelta{
+        NumTrackedRegions:    uint64(s.NumTrackedRegions),
+        NumActiveWatchpoints: uint64(s.NumActiveWatchpoints),
+        TotalEvents:          growth(s.TotalEvents, prev.TotalEvents),
+        RingWriteCount:       growth(s.RingWriteCount, prev.RingWriteCount),
+        RingDropCount:        growth(s.RingDropCount, prev.RingDropCount),
+        StorageBytesUsed:     s.StorageBytesUsed,
+        MprotectPageCount:    uint64(s.MprotectPageCount),
+        WorkerCycles:         growth(s.WorkerCycles, prev.WorkerCycles),
+    }
+}
+
+// PerSecond divides every counter delta by elapsed and copies the gauges.
+// A non-positive elapsed yields zero rates, gauges included.
+func (d StatsDelta) PerSecond(elapsed time.Duration) StatsRate {
+    secs := elapsed.Seconds()
+    if secs <= 0 {
+        return StatsRate{}
+    }
+    
+    return StatsRate{
+        NumTrackedRegions:    float64(d.NumTrackedRegions),
+        NumActiveWatchpoints: float64(d.NumActiveWatchpoints),
+        TotalEvents:          float64(d.TotalEvents) / secs,
+        RingWriteCount:       float64(d.RingWriteCount) / secs,
+        RingDropCount:        float64(d.RingDropCount) / secs,
+        StorageBytesUsed:     float64(d.StorageBytesUsed),
+        MprotectPageCount:    float64(d.MprotectPageCount),
+        WorkerCycles:         float64(d.WorkerCycles) / secs,
+    }
+}
+
+// growth returns cur - prev, or zero when the counter went backwards
+func growth(cur, prev uint64) uint64 {
+    if cur < prev {
+        return 0
+    }
+    return cur - prev
+}
+
+// Line protocol escaping: measurements escape commas and spaces, tag keys
+// and values also escape equals signs
+var (
+    measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+    tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// WriteLineProtocol writes the stats as one InfluxDB line protocol line:
+// measurement, the tags sorted by key, every counter as an integer field
+// and the current time in nanoseconds. Integer fields are signed, so a
+// uint64 counter beyond math.MaxInt64 is written as math.MaxInt64. Tags
+// with an empty key or value are left out, as the protocol does not allow
+// them.
+func (s *Stats) WriteLineProtocol(w io.Writer, measurement string, tags map[string]string) error {
+    var b strings.Builder
+    b.WriteString(measurementEscaper.Replace(measurement))
+    
+    keys := make([]string, 0, len(tags))
+    for key, value := range tags {
+        if key != "" && value != "" {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        fmt.Fprintf(&b, ",%s=%s", tagEscaper.Replace(key), tagEscaper.Replace(tags[key]))
+    }
+    
+    fmt.Fprintf(&b, " num_tracked_regions=%di,num_active_watchpoints=%di,total_events=%di,"+
+        "ring_write_count=%di,ring_drop_count=%di,storage_bytes_used=%di,"+
+        "mprotect_page_count=%di,worker_thread_id=%di,worker_cycles=%di,"+
+        "ring_occupancy=%di,ring_capacity=%di %d\n",
+        s.NumTrackedRegions, s.NumActiveWatchpoints, lineInt(s.TotalEvents),
+        lineInt(s.RingWriteCount), lineInt(s.RingDropCount), lineInt(s.StorageBytesUsed),
+        s.MprotectPageCount, s.WorkerThreadID, lineInt(s.WorkerCycles),
+        s.RingOccupancy, s.RingCapacity, time.Now().UnixNano())
+    
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+// lineInt clamps a counter to the signed range of a line protocol integer
+func lineInt(v uint64) int64 {
+    if v > math.MaxInt64 {
+        return math.MaxInt64
+    }
+    return int64(v)
+}
@@ -0,0 +1,1035 @@
+package memwatch
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "errors"
+    "encoding/json"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "runtime"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+    "unsafe"
+)
+
+// fakeNative is a pure-Go stand-in for libmemwatch_core. It snapshots each
+// watched region and reports a change event whenever the live bytes differ
+// from the snapshot on checkChanges. Events can also be injected directly.
+type fakeNative struct {
+    mu        sync.Mutex
+    regions   map[uint32]*fakeRegion
+    nextID    uint32
+    injected  []rawEvent
+    allocated int
+    freed     int
+    shutdowns int
+    failCode  int
+    watchCode int
+}
+
+type fakeRegion struct {
+    ptr      unsafe.Pointer
+    size     int
+    name     string
+    adapter  uint32
+    snapshot []byte
+}
+
+func newFakeNative() *fakeNative {
+    return &fakeNative{regions: make(map[uint32]*fakeRegion)}
+}
+
+func (f *fakeNative) init() int { return 0 }
+
+func (f *fakeNative) shutdown() {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.shutdowns++
+}
+
+func (f *fakeNative) watch(ptr unsafe.Pointer, size int, name string, adapterID uint32) (uint32, int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if code := f.watchCode; code != 0 {
+        f.watchCode = 0
+        return 0, code
+    }
+    f.nextID++
+    live := unsafe.Slice((*byte)(ptr), size)
+    f.regions[f.nextID] = &fakeRegion{ptr: ptr, size: size, name: name, adapter: adapterID, snapshot: append([]byte(nil), live...)}
+    return f.nextID, nativeOK
+}
+
+func (f *fakeNative) unwatch(regionID uint32) bool {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if _, ok := f.regions[regionID]; !ok {
+        return false
+    }
+    delete(f.regions, regionID)
+    return true
+}
+
+func (f *fakeNative) setCallback(enabled bool) int { return 0 }
+
+// inject queues a synthetic event for the next checkChanges call
+func (f *fakeNative) inject(evt rawEvent) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.injected = append(f.injected, evt)
+}
+
+// failNext makes the next checkChanges call fail with code
+func (f *fakeNative) failNext(code int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.failCode = code
+}
+
+// failNextWatch makes the next watch call fail with code
+func (f *fakeNative) failNextWatch(code int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.watchCode = code
+}
+
+func (f *fakeNative) checkChanges(max int) ([]rawEvent, int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    
+    if code := f.failCode; code != 0 {
+        f.failCode = 0
+        return nil, code
+    }
+
+    var events []rawEvent
+    for len(f.injected) > 0 && len(events) < max {
+        events = append(events, f.injected[0])
+        f.injected = f.injected[1:]
+    }
+
+    ids := make([]uint32, 0, len(f.regions))
+    for id := range f.regions {
+        ids = append(ids, id)
+    }
+    sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+    for _, id := range ids {
+        if len(events) >= max {
+            break
+        }
+        region := f.regions[id]
+        live := unsafe.Slice((*byte)(region.ptr), region.size)
+        if bytes.Equal(live, region.snapshot) {
+            continue
+        }
+        events = append(events, rawEvent{
+            adapterID:    region.adapter,
+            regionID:     id,
+            variableName: region.name,
+            oldPreview:   region.snapshot,
+            newPreview:   append([]byte(nil), live...),
+        })
+        region.snapshot = append([]byte(nil), live...)
+    }
+
+    for i := range events {
+        events[i].handle = unsafe.Pointer(new(byte))
+        f.allocated++
+    }
+    return events, len(events)
+}
+
+func (f *fakeNative) freeEvent(evt *rawEvent) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if evt.handle != nil {
+        evt.handle = nil
+        f.freed++
+    }
+}
+
+func (f *fakeNative) getStats() (Stats, int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return Stats{NumTrackedRegions: uint32(len(f.regions))}, 0
+}
+
+// leaked reports how many events were handed out but never freed
+func (f *fakeNative) leaked() int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.allocated - f.freed
+}
+
+func newFakeWatcher(t *testing.T) (*MemWatch, *fakeNative) {
+    t.Helper()
+    fake := newFakeNative()
+    w, err := newWatcher(fake)
+    if err != nil {
+        t.Fatalf("newWatcher: %v", err)
+    }
+    return w, fake
+}
+
+func TestEncodeDecodeEventsRoundTrip(t *testing.T) {
+    events := []*ChangeEvent{
+        {
+            Seq:          1,
+            TimestampNs:  1234567890,
+            AdapterID:    2,
+            RegionID:     3,
+            VariableName: "counter",
+            Where:        Location{File: "main.go", Function: "main.run", Line: 42, FaultIP: 0xdeadbeef},
+            OldPreview:   []byte{0x00, 0x01},
+            NewPreview:   []byte{0xff, 0x02},
+            OldValue:     []byte{0x00, 0x01, 0x02},
+            NewValue:     []byte{0xff, 0x02, 0x03},
+            Metadata: map[string]interface{}{
+                "thread": "worker-1",
+                "count":  7,
+                "nested": map[string]interface{}{"ok": true},
+            },
+        },
+        {
+            Seq:           2,
+            RegionID:      3,
+            VariableName:  "blob",
+            StorageKeyOld: "old-key",
+            StorageKeyNew: "new-key",
+            Metadata:      map[string]interface{}{},
+        },
+    }
+    
+    var buf bytes.Buffer
+    if err := EncodeEvents(&buf, events); err != nil {
+        t.Fatalf("EncodeEvents: %v", err)
+    }
+    
+    decoded, err := DecodeEvents(&buf)
+    if err != nil {
+        t.Fatalf("DecodeEvents: %v", err)
+    }
+    
+    if !reflect.DeepEqual(decoded, events) {
+        t.Errorf("round trip mismatch:\n got %+v\nwant %+v", decoded, events)
+    }
+}
+
+func TestServeHTTPEventsAndStats(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    buf := make([]byte, 8)
+    if _, err := w.Watch(buf, "buf"); err != nil {
+        t.Fatalf("Watch: %v", err)
+    }
+    buf[3] = 42
+    
+    srv, err := w.ServeHTTP("127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("ServeHTTP: %v", err)
+    }
+    
+    get := func(path string) *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        srv.Handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+        return rec
+    }
+    
+    var events []*ChangeEvent
+    deadline := time.Now().Add(2 * time.Second)
+    for len(events) == 0 && time.Now().Before(deadline) {
+        if err := json.Unmarshal(get("/events").Body.Bytes(), &events); err != nil {
+            t.Fatalf("decode /events: %v", err)
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+    if len(events) != 1 {
+        t.Fatalf("/events returned %d events, want 1", len(events))
+    }
+    if events[0].VariableName != "buf" || events[0].NewPreview[3] != 42 {
+        t.Errorf("unexpected event %+v", events[0])
+    }
+    
+    stats := get("/stats")
+    if !json.Valid(stats.Body.Bytes()) {
+        t.Fatalf("/stats returned invalid JSON: %s", stats.Body.String())
+    }
+    var decoded Stats
+    json.Unmarshal(stats.Body.Bytes(), &decoded)
+    if decoded.NumTrackedRegions != 1 {
+        t.Errorf("NumTrackedRegions = %d, want 1", decoded.NumTrackedRegions)
+    }
+    
+    var regions []WatchedRegion
+    if err := json.Unmarshal(get("/regions").Body.Bytes(), &regions); err != nil {
+        t.Fatalf("decode /regions: %v", err)
+    }
+    if len(regions) != 1 || regions[0].Name != "buf" || regions[0].Size != 8 {
+        t.Errorf("/regions = %+v", regions)
+    }
+}
+
+func TestStreamHandlerSendsSSEFrame(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    srv := httptest.NewServer(w.StreamHandler())
+    defer srv.Close()
+    
+    resp, err := http.Get(srv.URL)
+    if err != nil {
+        t.Fatalf("GET: %v", err)
+    }
+    defer resp.Body.Close()
+    
+    if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+        t.Fatalf("Content-Type = %q", ct)
+    }
+    
+    fake.inject(rawEvent{regionID: 5, variableName: "counter", newPreview: []byte{1}})
+    
+    line, err := bufio.NewReader(resp.Body).ReadString('\n')
+    if err != nil {
+        t.Fatalf("read frame: %v", err)
+    }
+    if !strings.HasPrefix(line, "data: ") {
+        t.Fatalf("frame = %q, want data: prefix", line)
+    }
+    
+    var evt ChangeEvent
+    if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &evt); err != nil {
+        t.Fatalf("decode frame: %v", err)
+    }
+    if evt.RegionID != 5 || evt.VariableName != "counter" {
+        t.Errorf("unexpected event %+v", evt)
+    }
+}
+
+func TestStreamHandlerAfterCloseStartsNoDrain(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    w.Close()
+    
+    srv := httptest.NewServer(w.StreamHandler())
+    defer srv.Close()
+    
+    resp, err := http.Get(srv.URL)
+    if err != nil {
+        t.Fatalf("GET: %v", err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusServiceUnavailable {
+        t.Errorf("status after Close = %d, want 503", resp.StatusCode)
+    }
+    if w.http != nil {
+        t.Error("stream request after Close created HTTP state")
+    }
+    
+    if _, err := w.ServeHTTP("127.0.0.1:0"); !errors.Is(err, ErrClosed) {
+        t.Errorf("ServeHTTP after Close error = %v, want ErrClosed", err)
+    }
+}
+
+// cancelAfterContext reports itself cancelled once Done has been polled
+// more than n times, to cancel deterministically in the middle of a drain
+type cancelAfterContext struct {
+    context.Context
+    n      int
+    cancel context.CancelFunc
+}
+
+func (c *cancelAfterContext) Done() <-chan struct{} {
+    if c.n--; c.n < 0 {
+        c.cancel()
+    }
+    return c.Context.Done()
+}
+
+func TestCheckChangesContextAlreadyCancelled(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    fake.inject(rawEvent{regionID: 1})
+    
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    
+    events, err := w.CheckChangesContext(ctx)
+    if !errors.Is(err, context.Canceled) {
+        t.Fatalf("err = %v, want context.Canceled", err)
+    }
+    if events != nil {
+        t.Errorf("got %d events from a cancelled check", len(events))
+    }
+    if n := fake.leaked(); n != 0 {
+        t.Errorf("%d native events leaked", n)
+    }
+}
+
+func TestCheckChangesContextCancelledMidDrainFreesEvents(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    for i := 0; i < 4; i++ {
+        fake.inject(rawEvent{regionID: uint32(i + 1)})
+    }
+    
+    parent, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    ctx := &cancelAfterContext{Context: parent, n: 2, cancel: cancel}
+    
+    if _, err := w.CheckChangesContext(ctx); !errors.Is(err, context.Canceled) {
+        t.Fatalf("err = %v, want context.Canceled", err)
+    }
+    if fake.allocated != 4 {
+        t.Fatalf("drained %d native events, want 4", fake.allocated)
+    }
+    if n := fake.leaked(); n != 0 {
+        t.Errorf("%d native events leaked", n)
+    }
+}
+
+func TestLocationSymbolizeResolvesGoPC(t *testing.T) {
+    pc, _, _, ok := runtime.Caller(0)
+    if !ok {
+        t.Fatal("runtime.Caller failed")
+    }
+    
+    loc := Location{FaultIP: uint64(pc)}
+    sym, err := loc.Symbolize()
+    if err != nil {
+        t.Fatalf("Symbolize: %v", err)
+    }
+    if !strings.Contains(sym, "TestLocationSymbolizeResolvesGoPC") {
+        t.Errorf("Symbolize() = %q, want the test function", sym)
+    }
+    if !strings.Contains(loc.Function, "TestLocationSymbolizeResolvesGoPC") || loc.Line == 0 {
+        t.Errorf("Location = %+v, want Function and Line filled in", loc)
+    }
+}
+
+func TestLocationSymbolizeFallsBackToHex(t *testing.T) {
+    loc := Location{FaultIP: 0x10}
+    sym, err := loc.Symbolize()
+    if err != nil || sym != "0x10" {
+        t.Errorf("Symbolize() = %q, %v, want \"0x10\"", sym, err)
+    }
+    if loc.Function != "" {
+        t.Errorf("Function = %q, want it left empty", loc.Function)
+    }
+    
+    if _, err := (&Location{}).Symbolize(); err == nil {
+        t.Error("expected error without a fault address")
+    }
+}
+
+func TestCheckChangesSymbolizesFaultIP(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    pc, _, _, _ := runtime.Caller(0)
+    fake.inject(rawEvent{regionID: 1, faultIP: uint64(pc)})
+    
+    events, err := w.CheckChanges()
+    if err != nil || len(events) != 1 {
+        t.Fatalf("CheckChanges = %d events, %v", len(events), err)
+    }
+    if !strings.Contains(events[0].Where.Function, "TestCheckChangesSymbolizesFaultIP") {
+        t.Errorf("Where = %+v, want Function resolved from FaultIP", events[0].Where)
+    }
+}
+
+func TestWatchErrorsMatchSentinels(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    if _, err := w.Watch([]string{"x"}, "strings"); !errors.Is(err, ErrUnsupportedType) {
+        t.Errorf("Watch([]string) err = %v, want ErrUnsupportedType", err)
+    }
+    if _, err := w.Watch([]byte{}, "empty"); !errors.Is(err, ErrEmptySlice) {
+        t.Errorf("Watch(empty) err = %v, want ErrEmptySlice", err)
+    }
+    
+    buf := make([]byte, 16)
+    if _, err := w.Watch(buf[:8], "low"); err != nil {
+        t.Fatalf("Watch: %v", err)
+    }
+    if _, err := w.Watch(buf[4:12], "mid"); !errors.Is(err, ErrOverlap) {
+        t.Errorf("Watch(overlapping) err = %v, want ErrOverlap", err)
+    }
+    if _, err := w.Watch(buf[8:], "high"); err != nil {
+        t.Errorf("Watch(adjacent) err = %v", err)
+    }
+}
+
+func TestWatchTellsNilFromEmpty(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    tests := []struct {
+        name    string
+        data    interface{}
+        want    error
+        notWant error
+    }{
+        {"nil bytes", []byte(nil), ErrNilData, ErrEmptySlice},
+        {"nil ints", []int(nil), ErrNilData, ErrEmptySlice},
+        {"nil interface", nil, ErrNilData, ErrUnsupportedType},
+        {"empty bytes", []byte{}, ErrEmptySlice, ErrNilData},
+        {"empty ints", make([]int, 0), ErrEmptySlice, ErrNilData},
+    }
+    for _, tt := range tests {
+        _, err := w.Watch(tt.data, tt.name)
+        if !errors.Is(err, tt.want) || errors.Is(err, tt.notWant) {
+            t.Errorf("Watch(%s) err = %v, want %v", tt.name, err, tt.want)
+        }
+    }
+    
+    id, err := w.Watch(make([]byte, 4), "valid")
+    if err != nil || id == 0 {
+        t.Errorf("Watch(valid) = %d, %v", id, err)
+    }
+}
+
+func TestCodeErrorCarriesNativeCode(t *testing.T) {
+    err := error(newCodeError(ErrInitFailed, "failed to initialize memwatch", -1))
+    
+    if !errors.Is(err, ErrInitFailed) {
+        t.Errorf("errors.Is(%v, ErrInitFailed) = false", err)
+    }
+    var codeErr *CodeError
+    if !errors.As(err, &codeErr) || codeErr.Code() != -1 {
+        t.Errorf("errors.As(%v) did not expose code -1", err)
+    }
+    if err.Error() != "failed to initialize memwatch: -1" {
+        t.Errorf("Error() = %q", err.Error())
+    }
+}
+
+func TestWatchMapsNativeCodes(t *testing.T) {
+    tests := []struct {
+        code int
+        want error
+    }{
+        {nativeErrNotInit, ErrNotInitialized},
+        {nativeErrInvalidAddr, ErrInvalidRegion},
+        {nativeErrNoMemory, ErrNative},
+    }
+    
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    for _, tt := range tests {
+        fake.failNextWatch(tt.code)
+        _, err := w.Watch(make([]byte, 4), "buf")
+        if !errors.Is(err, tt.want) {
+            t.Errorf("code %d: err = %v, want %v", tt.code, err, tt.want)
+        }
+        var codeErr *CodeError
+        if !errors.As(err, &codeErr) || codeErr.Code() != tt.code {
+            t.Errorf("code %d: err = %v, want a CodeError with that code", tt.code, err)
+        }
+    }
+    if _, ok := w.RegionByName("buf"); ok {
+        t.Error("a failed watch left a region named buf")
+    }
+}
+
+func TestWatchStringBackingBytes(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    s := string([]byte("secret-token"))
+    id, err := w.Watch(s, "token")
+    if err != nil || id == 0 {
+        t.Fatalf("Watch(string) = %d, %v, want a region id", id, err)
+    }
+    if _, err := w.Watch("", "empty"); !errors.Is(err, ErrEmptySlice) {
+        t.Errorf("Watch(\"\") err = %v, want ErrEmptySlice", err)
+    }
+}
+
+func TestStatsSubPerSecond(t *testing.T) {
+    prev := &Stats{TotalEvents: 100, RingDropCount: 10, RingWriteCount: 500, WorkerCycles: 7}
+    cur := &Stats{TotalEvents: 400, RingDropCount: 25, RingWriteCount: 200, WorkerCycles: 7}
+    
+    delta := cur.Sub(prev)
+    if delta.TotalEvents != 300 || delta.RingDropCount != 15 {
+        t.Errorf("delta = %+v, want TotalEvents 300, RingDropCount 15", delta)
+    }
+    if delta.RingWriteCount != 0 {
+        t.Errorf("RingWriteCount delta = %d, want 0 after wraparound", delta.RingWriteCount)
+    }
+    
+    rate := delta.PerSecond(2 * time.Second)
+    if rate.TotalEvents != 150 || rate.RingDropCount != 7.5 || rate.WorkerCycles != 0 {
+        t.Errorf("rate = %+v, want TotalEvents 150/s, RingDropCount 7.5/s", rate)
+    }
+    if zero := delta.PerSecond(0); zero != (StatsRate{}) {
+        t.Errorf("PerSecond(0) = %+v, want zero rates", zero)
+    }
+}
+
+func TestStatsSubCarriesGauges(t *testing.T) {
+    prev := &Stats{NumTrackedRegions: 5, StorageBytesUsed: 4096, MprotectPageCount: 3}
+    cur := &Stats{NumTrackedRegions: 2, StorageBytesUsed: 8192, MprotectPageCount: 3}
+    
+    delta := cur.Sub(prev)
+    if delta.NumTrackedRegions != 2 || delta.StorageBytesUsed != 8192 || delta.MprotectPageCount != 3 {
+        t.Errorf("delta = %+v, want the current gauge values 2, 8192, 3", delta)
+    }
+    
+    rate := delta.PerSecond(2 * time.Second)
+    if rate.NumTrackedRegions != 2 || rate.StorageBytesUsed != 8192 || rate.MprotectPageCount != 3 {
+        t.Errorf("rate = %+v, want gauges unchanged by elapsed", rate)
+    }
+}
+
+func TestSetMaxPreviewBytesTruncates(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    big := bytes.Repeat([]byte{0xAB}, 1024)
+    w.SetMaxPreviewBytes(16)
+    fake.inject(rawEvent{regionID: 1, newPreview: big, oldValue: big, oldPreview: []byte{1, 2}})
+    fake.inject(rawEvent{regionID: 2, newPreview: big[:16]})
+    
+    events, err := w.CheckChanges()
+    if err != nil || len(events) != 2 {
+        t.Fatalf("CheckChanges = %d events, %v", len(events), err)
+    }
+    
+    evt := events[0]
+    if len(evt.NewPreview) != 16 || len(evt.OldValue) != 16 || len(evt.OldPreview) != 2 {
+        t.Errorf("lengths new=%d oldValue=%d old=%d, want 16/16/2",
+            len(evt.NewPreview), len(evt.OldValue), len(evt.OldPreview))
+    }
+    if !evt.Truncated {
+        t.Error("Truncated not set on a clipped event")
+    }
+    if events[1].Truncated {
+        t.Error("Truncated set on an event within the limit")
+    }
+}
+
+func TestUnwatchAllKeepsWatcherUsable(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    for i := 0; i < 3; i++ {
+        if _, err := w.Watch(make([]byte, 8), "buf"); err != nil {
+            t.Fatalf("Watch: %v", err)
+        }
+    }
+    
+    if n := w.UnwatchAll(); n != 3 {
+        t.Errorf("UnwatchAll() = %d, want 3", n)
+    }
+    stats, err := w.GetStats()
+    if err != nil || stats.NumTrackedRegions != 0 {
+        t.Errorf("GetStats = %+v, %v, want no tracked regions", stats, err)
+    }
+    if fake.shutdowns != 0 {
+        t.Error("UnwatchAll shut the native layer down")
+    }
+    
+    if id, err := w.Watch(make([]byte, 8), "again"); err != nil || id == 0 {
+        t.Errorf("Watch after UnwatchAll = %d, %v", id, err)
+    }
+}
+
+func TestCloseTwiceIsNoOp(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    
+    w.Close()
+    w.Close()
+    
+    if fake.shutdowns != 1 {
+        t.Errorf("shutdowns = %d, want 1", fake.shutdowns)
+    }
+    if _, err := w.Watch(make([]byte, 8), "late"); !errors.Is(err, ErrClosed) {
+        t.Errorf("Watch after Close err = %v, want ErrClosed", err)
+    }
+    if _, err := w.CheckChanges(); !errors.Is(err, ErrClosed) {
+        t.Errorf("CheckChanges after Close err = %v, want ErrClosed", err)
+    }
+}
+
+func TestSetMaxRegionsHardLimit(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    w.SetMaxRegions(2)
+    first, _ := w.Watch(make([]byte, 8), "a")
+    if _, err := w.Watch(make([]byte, 8), "b"); err != nil {
+        t.Fatalf("Watch within limit: %v", err)
+    }
+    if _, err := w.Watch(make([]byte, 8), "c"); !errors.Is(err, ErrRegionLimit) {
+        t.Errorf("Watch over limit err = %v, want ErrRegionLimit", err)
+    }
+    
+    w.Unwatch(first)
+    if _, err := w.Watch(make([]byte, 8), "c"); err != nil {
+        t.Errorf("Watch after Unwatch freed a slot: %v", err)
+    }
+}
+
+func TestOnNearLimitFiresAtThreshold(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    var calls [][2]int
+    w.SetMaxRegions(10)
+    w.OnNearLimit(0.8, func(current, max int) {
+        calls = append(calls, [2]int{current, max})
+    })
+    
+    ids := make([]uint32, 0, 9)
+    for i := 0; i < 9; i++ {
+        id, err := w.Watch(make([]byte, 8), "buf")
+        if err != nil {
+            t.Fatalf("Watch %d: %v", i, err)
+        }
+        ids = append(ids, id)
+        if i == 6 && len(calls) != 0 {
+            t.Fatalf("callback fired at %d regions", i+1)
+        }
+    }
+    if len(calls) != 1 || calls[0] != [2]int{8, 10} {
+        t.Errorf("calls = %v, want a single call at 8 of 10", calls)
+    }
+    
+    w.Unwatch(ids[8])
+    w.Unwatch(ids[7])
+    w.Watch(make([]byte, 8), "again")
+    if len(calls) != 2 {
+        t.Errorf("callback did not fire again after dropping below the threshold, calls = %v", calls)
+    }
+}
+
+func TestWatchWithAdapterTagsEvents(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    routed := make([]byte, 8)
+    plain := make([]byte, 8)
+    if _, err := w.WatchWithAdapter(routed, "routed", 7); err != nil {
+        t.Fatalf("WatchWithAdapter: %v", err)
+    }
+    if _, err := w.Watch(plain, "plain"); err != nil {
+        t.Fatalf("Watch: %v", err)
+    }
+    
+    routed[0] = 1
+    plain[0] = 1
+    
+    events, err := w.CheckChanges()
+    if err != nil || len(events) != 2 {
+        t.Fatalf("CheckChanges = %d events, %v, want 2", len(events), err)
+    }
+    for _, evt := range events {
+        want := uint32(0)
+        if evt.VariableName == "routed" {
+            want = 7
+        }
+        if evt.AdapterID != want {
+            t.Errorf("%s: AdapterID = %d, want %d", evt.VariableName, evt.AdapterID, want)
+        }
+    }
+}
+
+func TestRegionByNameAndUnwatchByName(t *testing.T) {
+    w, _ := newFakeWatcher(t)
+    defer w.Close()
+    
+    config, _ := w.Watch(make([]byte, 8), "config")
+    session, _ := w.Watch(make([]byte, 8), "session")
+    
+    if id, ok := w.RegionByName("config"); !ok || id != config {
+        t.Errorf("RegionByName(config) = %d, %v, want %d", id, ok, config)
+    }
+    if id, ok := w.RegionByName("session"); !ok || id != session {
+        t.Errorf("RegionByName(session) = %d, %v, want %d", id, ok, session)
+    }
+    
+    newer, _ := w.Watch(make([]byte, 8), "session")
+    if id, _ := w.RegionByName("session"); id != newer {
+        t.Errorf("RegionByName(session) = %d, want the newest region %d", id, newer)
+    }
+    
+    if !w.UnwatchByName("session") {
+        t.Fatal("UnwatchByName(session) = false")
+    }
+    if id, _ := w.RegionByName("session"); id != session {
+        t.Errorf("after unwatching the newest, RegionByName(session) = %d, want %d", id, session)
+    }
+    if w.UnwatchByName("missing") {
+        t.Error("UnwatchByName(missing) = true")
+    }
+}
+
+func TestStatsRingUsage(t *testing.T) {
+    tests := []struct {
+        occupancy, capacity uint32
+        want                float64
+    }{
+        {0, 1024, 0},
+        {256, 1024, 0.25},
+        {1024, 1024, 1},
+        {7, 0, 0},
+    }
+    for _, tt := range tests {
+        stats := &Stats{RingOccupancy: tt.occupancy, RingCapacity: tt.capacity}
+        if got := stats.RingUsage(); got != tt.want {
+            t.Errorf("RingUsage() with %d of %d = %v, want %v", tt.occupancy, tt.capacity, got, tt.want)
+        }
+    }
+}
+
+func TestStatsWriteLineProtocol(t *testing.T) {
+    stats := &Stats{NumTrackedRegions: 3, TotalEvents: 42, RingDropCount: 1, WorkerCycles: 900}
+    
+    var b bytes.Buffer
+    tags := map[string]string{"host": "db 1", "app": "api", "empty": ""}
+    if err := stats.WriteLineProtocol(&b, "memwatch", tags); err != nil {
+        t.Fatalf("WriteLineProtocol: %v", err)
+    }
+    line := b.String()
+    if !strings.HasSuffix(line, "\n") {
+        t.Fatalf("line %q does not end in a newline", line)
+    }
+    
+    parts := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+    if len(parts) != 4 {
+        t.Fatalf("line %q has %d space-separated parts, want 4 counting the escaped space", line, len(parts))
+    }
+    if series := parts[0] + " " + parts[1]; series != `memwatch,app=api,host=db\ 1` {
+        t.Errorf("series = %q, want sorted, escaped tags", series)
+    }
+    
+    fields := make(map[string]string)
+    for _, field := range strings.Split(parts[2], ",") {
+        kv := strings.SplitN(field, "=", 2)
+        fields[kv[0]] = kv[1]
+    }
+    if len(fields) != 11 || fields["num_tracked_regions"] != "3i" || fields["total_events"] != "42i" ||
+        fields["ring_drop_count"] != "1i" || fields["worker_cycles"] != "900i" {
+        t.Errorf("fields = %v", fields)
+    }
+    if _, err := strconv.ParseInt(parts[3], 10, 64); err != nil {
+        t.Errorf("timestamp %q: %v", parts[3], err)
+    }
+}
+
+func TestStatsWriteLineProtocolClampsLargeCounters(t *testing.T) {
+    stats := &Stats{TotalEvents: math.MaxUint64}
+    
+    var b bytes.Buffer
+    if err := stats.WriteLineProtocol(&b, "memwatch", nil); err != nil {
+        t.Fatalf("WriteLineProtocol: %v", err)
+    }
+    if want := "total_events=9223372036854775807i,"; !strings.Contains(b.String(), want) {
+        t.Errorf("line %q does not contain %q", b.String(), want)
+    }
+}
+
+func TestMarshalEventsNamingStyles(t *testing.T) {
+    events := []ChangeEvent{{
+        Seq:          1,
+        RegionID:     4,
+        VariableName: "config",
+        Where:        Location{Function: "main.update", FaultIP: 0x1000},
+        OldValue:     []byte{1},
+        NewValue:     []byte{2},
+        Metadata:     map[string]interface{}{"user_id": 7},
+    }}
+    
+    tests := []struct {
+        style    NamingStyle
+        keys     []string
+        location string
+    }{
+        {SnakeCase, []string{"variable_name", "region_id", "old_value", "timestamp_ns"}, "fault_ip"},
+        {CamelCase, []string{"variableName", "regionId", "oldValue", "timestampNs"}, "faultIp"},
+    }
+    
+    for _, tt := range tests {
+        data, err := MarshalEvents(events, tt.style)
+        if err != nil {
+            t.Fatalf("MarshalEvents(%d): %v", tt.style, err)
+        }
+        var decoded []map[string]interface{}
+        if err := json.Unmarshal(data, &decoded); err != nil || len(decoded) != 1 {
+            t.Fatalf("MarshalEvents(%d) = %s: %v", tt.style, data, err)
+        }
+        
+        evt := decoded[0]
+        for _, key := range tt.keys {
+            if _, ok := evt[key]; !ok {
+                t.Errorf("style %d: output lacks %q: %s", tt.style, key, data)
+            }
+        }
+        if where, _ := evt["where"].(map[string]interface{}); where[tt.location] == nil {
+            t.Errorf("style %d: location lacks %q: %s", tt.style, tt.location, data)
+        }
+        if meta, _ := evt["metadata"].(map[string]interface{}); meta["user_id"] == nil {
+            t.Errorf("style %d: metadata keys were restyled: %s", tt.style, data)
+        }
+    }
+}
+
+func TestStartPollingDeliversEventsAndErrors(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    events, errs := w.StartPolling(ctx, time.Millisecond)
+    
+    // Injected rather than written to watched memory, which the polling
+    // goroutine reads concurrently
+    fake.inject(rawEvent{regionID: 1, variableName: "buf", newPreview: []byte{1}})
+    select {
+    case evt := <-events:
+        if evt.VariableName != "buf" {
+            t.Errorf("event for %q, want buf", evt.VariableName)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("no event delivered")
+    }
+    
+    fake.failNext(-3)
+    select {
+    case err := <-errs:
+        var codeErr *CodeError
+        if !errors.As(err, &codeErr) || codeErr.Code() != -3 {
+            t.Errorf("err = %v, want a CodeError with code -3", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("no error delivered")
+    }
+    
+    cancel()
+    for range events {
+    }
+    for range errs {
+    }
+    if drops := w.PollDrops(); drops != 0 {
+        t.Errorf("PollDrops() = %d with an attentive consumer", drops)
+    }
+}
+
+func TestCheckChangesIntoReusesBuffers(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    
+    buf := make([]*ChangeEvent, 4)
+    scratch := make([]byte, 8)
+    
+    fake.inject(rawEvent{regionID: 1, variableName: "a", oldPreview: []byte{1, 2}, newPreview: []byte{3, 4}})
+    fake.inject(rawEvent{regionID: 2, variableName: "b", newPreview: []byte{5, 6, 7, 8, 9, 10}})
+    n, err := w.CheckChangesInto(buf, scratch)
+    if err != nil || n != 2 {
+        t.Fatalf("CheckChangesInto = %d, %v, want 2 events", n, err)
+    }
+    
+    first, second := buf[0], buf[1]
+    if !bytes.Equal(first.OldPreview, []byte{1, 2}) || !bytes.Equal(first.NewPreview, []byte{3, 4}) || first.Truncated {
+        t.Errorf("first event = %+v", first)
+    }
+    if !bytes.Equal(second.NewPreview, []byte{5, 6, 7, 8}) || !second.Truncated {
+        t.Errorf("second event preview = %v truncated %v, want the 4 bytes left in scratch", second.NewPreview, second.Truncated)
+    }
+    if &first.OldPreview[0] != &scratch[0] {
+        t.Error("previews do not alias scratch")
+    }
+    if fake.leaked() != 0 {
+        t.Errorf("%d native events leaked", fake.leaked())
+    }
+    
+    fake.inject(rawEvent{regionID: 3, variableName: "c", newPreview: []byte{42}})
+    if n, _ := w.CheckChangesInto(buf, scratch); n != 1 || buf[0] != first {
+        t.Fatalf("second call filled %d events, reused first = %v", n, buf[0] == first)
+    }
+    if first.VariableName != "c" || !bytes.Equal(first.NewPreview, []byte{42}) || first.OldPreview != nil || first.Truncated {
+        t.Errorf("reused event = %+v, want only event c's fields", first)
+    }
+}
+
+func benchmarkCheckChanges(b *testing.B, into bool) {
+    w, fake := newFakeWatcher(&testing.T{})
+    defer w.Close()
+    
+    preview := bytes.Repeat([]byte{0xAB}, 64)
+    buf := make([]*ChangeEvent, 16)
+    scratch := make([]byte, 16*4*len(preview))
+    
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        for j := 0; j < 16; j++ {
+            fake.inject(rawEvent{regionID: 1, oldPreview: preview, newPreview: preview, oldValue: preview, newValue: preview})
+        }
+        if into {
+            w.CheckChangesInto(buf, scratch)
+        } else {
+            w.CheckChanges()
+        }
+    }
+}
+
+func BenchmarkCheckChanges(b *testing.B) { benchmarkCheckChanges(b, false) }
+
+func BenchmarkCheckChangesInto(b *testing.B) { benchmarkCheckChanges(b, true) }
+
+func TestSetMinChangeBytesDropsSmallChanges(t *testing.T) {
+    w, fake := newFakeWatcher(t)
+    defer w.Close()
+    w.SetMinChangeBytes(2)
+    
+    inject := func() {
+        fake.inject(rawEvent{variableName: "flip", oldPreview: []byte{0, 0, 0}, newPreview: []byte{0, 1, 0}})
+        fake.inject(rawEvent{variableName: "pair", oldPreview: []byte{0, 0, 0}, newPreview: []byte{1, 1, 0}})
+        fake.inject(rawEvent{variableName: "grown", oldPreview: []byte{7}, newPreview: []byte{7, 8, 9}})
+        fake.inject(rawEvent{variableName: "same", oldPreview: []byte{4, 4}, newPreview: []byte{4, 4}})
+    }
+    
+    inject()
+    events, err := w.CheckChanges()
+    if err != nil {
+        t.Fatalf("CheckChanges: %v", err)
+    }
+    var names []string
+    for _, evt := range events {
+        names = append(names, evt.VariableName)
+    }
+    if want := []string{"pair", "grown"}; !reflect.DeepEqual(names, want) {
+        t.Errorf("CheckChanges returned %v, want %v", names, want)
+    }
+    if fake.leaked() != 0 {
+        t.Errorf("%d dropped events were not freed", fake.leaked())
+    }
+    
+    inject()
+    buf := make([]*ChangeEvent, 4)
+    if n, _ := w.CheckChangesInto(buf, make([]byte, 64)); n != 2 || buf[0].VariableName != "pair" || buf[1].VariableName != "grown" {
+        t.Errorf("CheckChangesInto filled %d events starting %+v, want pair and grown", n, buf[0])
+    }
+    if fake.leaked() != 0 {
+        t.Errorf("%d dropped events were not freed by CheckChangesInto", fake.leaked())
+    }
+    
+    w.SetMinChangeBytes(0)
+    inject()
+    if events, _ := w.CheckChanges(); len(events) != 4 {
+        t.Errorf("with no minimum CheckChanges returned %d events, want 4", len(events))
+    }
+}
@@ -0,0 +1,142 @@
+// Package memwatchtest provides a pure-Go stand-in for memwatch.MemWatch,
+// so code using the memwatch.Watcher interface can be unit-tested without
+// the native library.
+package memwatchtest
+
+import (
+    "fmt"
+    "sync"
+
+    memwatch "github.com/specifiedone/WaterCodeFlow/memwatch/bindings"
+)
+
+// FakeWatcher is a memwatch.Watcher that never looks at memory. Watched
+// regions are only recorded; changes are whatever the test passes to
+// EmitEvent. It is safe for concurrent use.
+type FakeWatcher struct {
+    mu       sync.Mutex
+    nextID   uint32
+    regions  map[uint32]string
+    pending  []*memwatch.ChangeEvent
+    callback memwatch.ChangeEventCallback
+    emitted  uint64
+    closed   bool
+}
+
+var _ memwatch.Watcher = (*FakeWatcher)(nil)
+
+// NewFakeWatcher returns a FakeWatcher with no regions and no events
+func NewFakeWatcher() *FakeWatcher {
+    return &FakeWatcher{regions: make(map[uint32]string)}
+}
+
+// Watch records a region under name and returns its id. Like MemWatch it
+// accepts non-empty []byte, []int and string values and tells nil slices
+// apart from empty ones.
+func (f *FakeWatcher) Watch(data interface{}, name string) (uint32, error) {
+    var size int
+    switch v := data.(type) {
+    case nil:
+        return 0, memwatch.ErrNilData
+    case []byte:
+        if v == nil {
+            return 0, memwatch.ErrNilData
+        }
+        size = len(v)
+    case []int:
+        if v == nil {
+            return 0, memwatch.ErrNilData
+        }
+        size = len(v)
+    case string:
+        size = len(v)
+    default:
+        return 0, fmt.Errorf("%w: %T", memwatch.ErrUnsupportedType, v)
+    }
+    if size == 0 {
+        return 0, memwatch.ErrEmptySlice
+    }
+
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    if f.closed {
+        return 0, memwatch.ErrClosed
+    }
+    f.nextID++
+    f.regions[f.nextID] = name
+    return f.nextID, nil
+}
+
+// Unwatch forgets a region, reporting whether it was watched
+func (f *FakeWatcher) Unwatch(region_id uint32) bool {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    if _, ok := f.regions[region_id]; !ok {
+        return false
+    }
+    delete(f.regions, region_id)
+    return true
+}
+
+// EmitEvent queues evt for the next CheckChanges call and passes it to the
+// callback, if one is set, on the calling goroutine. A zero VariableName
+// is filled in from the region named by evt.RegionID.
+func (f *FakeWatcher) EmitEvent(evt *memwatch.ChangeEvent) {
+    f.mu.Lock()
+    if evt.VariableName == "" {
+        evt.VariableName = f.regions[evt.RegionID]
+    }
+    f.pending = append(f.pending, evt)
+    f.emitted++
+    callback := f.callback
+    f.mu.Unlock()
+
+    if callback != nil {
+        callback(evt)
+    }
+}
+
+// CheckChanges returns the events emitted since the last call
+func (f *FakeWatcher) CheckChanges() ([]*memwatch.ChangeEvent, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    if f.closed {
+        return nil, memwatch.ErrClosed
+    }
+    events := f.pending
+    f.pending = nil
+    return events, nil
+}
+
+// GetStats reports the watched regions and how many events were emitted
+func (f *FakeWatcher) GetStats() (*memwatch.Stats, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    return &memwatch.Stats{
+        NumTrackedRegions: uint32(len(f.regions)),
+        TotalEvents:       f.emitted,
+    }, nil
+}
+
+// SetCallback sets the function EmitEvent passes events to; nil removes it
+func (f *FakeWatcher) SetCallback(callback memwatch.ChangeEventCallback) error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.callback = callback
+    return nil
+}
+
+// Close forgets all regions and pending events. Watch and CheckChanges
+// fail with memwatch.ErrClosed afterwards.
+func (f *FakeWatcher) Close() {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    f.closed = true
+    f.regions = make(map[uint32]string)
+    f.pending = nil
+}
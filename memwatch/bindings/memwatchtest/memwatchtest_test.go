@@ -0,0 +1,64 @@
+package memwatchtest
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+
+    memwatch "github.com/specifiedone/WaterCodeFlow/memwatch/bindings"
+)
+
+// changedNames is a typical consumer: it polls a watcher once and reports
+// the names of the variables that changed
+func changedNames(w memwatch.Watcher) ([]string, error) {
+    events, err := w.CheckChanges()
+    if err != nil {
+        return nil, err
+    }
+
+    var names []string
+    for _, evt := range events {
+        names = append(names, evt.VariableName)
+    }
+    return names, nil
+}
+
+func TestFakeWatcherDrivesConsumer(t *testing.T) {
+    fake := NewFakeWatcher()
+    defer fake.Close()
+
+    counter, err := fake.Watch(make([]byte, 8), "counter")
+    if err != nil {
+        t.Fatalf("Watch: %v", err)
+    }
+    flags, _ := fake.Watch([]int{0, 1}, "flags")
+
+    var called []*memwatch.ChangeEvent
+    fake.SetCallback(func(evt *memwatch.ChangeEvent) { called = append(called, evt) })
+
+    fake.EmitEvent(&memwatch.ChangeEvent{RegionID: counter, NewPreview: []byte{1}})
+    fake.EmitEvent(&memwatch.ChangeEvent{RegionID: flags})
+
+    names, err := changedNames(fake)
+    if err != nil {
+        t.Fatalf("changedNames: %v", err)
+    }
+    if want := []string{"counter", "flags"}; !reflect.DeepEqual(names, want) {
+        t.Errorf("consumer saw %v, want %v", names, want)
+    }
+    if len(called) != 2 || called[0].RegionID != counter {
+        t.Errorf("callback got %d events, want both", len(called))
+    }
+
+    if names, _ := changedNames(fake); len(names) != 0 {
+        t.Errorf("second poll saw %v, want nothing", names)
+    }
+    if stats, _ := fake.GetStats(); stats.NumTrackedRegions != 2 || stats.TotalEvents != 2 {
+        t.Errorf("stats = %+v, want 2 regions and 2 events", stats)
+    }
+
+    fake.Close()
+    if _, err := changedNames(fake); !errors.Is(err, memwatch.ErrClosed) {
+        t.Errorf("poll after Close error = %v, want ErrClosed", err)
+    }
+}
@@ -0,0 +1,871 @@
+package sqltracker
+
+import (
+	"C"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SQL operation types
+const (
+	OpUnknown = iota
+	OpInsert
+	OpUpdate
+	OpDelete
+	OpSelect
+	OpCreate
+	OpAlter
+	OpDrop
+	OpTruncate
+)
+
+// SQLChange represents a single column change
+type SQLChange struct {
+	TimestampNs     int64             `json:"timestamp_ns"`
+	TableName       string            `json:"table_name"`
+	ColumnName      string            `json:"column_name"`
+	Operation       int               `json:"operation"`
+	OldValue        string            `json:"old_value"`
+	NewValue        string            `json:"new_value"`
+	RowsAffected    int               `json:"rows_affected"`
+	Database        string            `json:"database"`
+	FullQuery       string            `json:"full_query"`
+	Where           map[string]string `json:"where"`
+	ParseError      bool              `json:"parse_error"`
+	RowIndex        int               `json:"row_index"`
+	Fingerprint     string            `json:"fingerprint"`
+	NoOp            bool              `json:"no_op"`
+	QueryTruncated  bool              `json:"query_truncated"`
+	SourceTables    []string          `json:"source_tables"`
+	Predicate       string            `json:"predicate"`
+	FullTableDelete bool              `json:"full_table_delete"`
+	InferredType    string            `json:"inferred_type"`
+}
+
+// SQLTracker tracks SQL column-level changes
+type SQLTracker struct {
+	mu           sync.RWMutex
+	tracker      unsafe.Pointer
+	storage      Storage
+	changes      []SQLChange
+	dedup        bool
+	parseErrors  int
+	persistErrs  int
+	persistErr   error
+	redacted     map[string]bool
+	tracked      map[string]bool
+	maxQuery     int
+	mask         string
+	now          func() time.Time
+	callbacks    []func(SQLChange)
+	wsOnce       sync.Once
+	ws           *wsHub
+	tracer       trace.Tracer
+	rowCache     map[string]map[string]string
+}
+
+// New creates a new SQL tracker persisting to a JSONL file at storagePath,
+// gzip-compressed when the path ends in ".jsonl.gz". An empty path keeps
+// changes in memory only.
+func New(storagePath string) *SQLTracker {
+	switch {
+	case storagePath == "":
+		return NewWithStorage(nil)
+	case strings.HasSuffix(storagePath, ".jsonl.gz"):
+		return NewWithStorage(NewGzipJSONLStorage(storagePath))
+	}
+	return NewWithStorage(NewJSONLStorage(storagePath))
+}
+
+// NewWithStorage creates a new SQL tracker persisting to s. Changes already
+// held by s are loaded as the tracker's initial history. A nil s keeps
+// changes in memory only.
+func NewWithStorage(s Storage) *SQLTracker {
+	// This would load and call the C library
+	// C.sql_tracker_init(...)
+	
+	changes := make([]SQLChange, 0)
+	if s != nil {
+		// Keep whatever loaded before an error, e.g. a truncated tail
+		loaded, _ := s.LoadAll()
+		changes = append(changes, loaded...)
+	}
+	
+	return &SQLTracker{
+		tracker: nil,
+		storage: s,
+		changes: changes,
+		now:     time.Now,
+	}
+}
+
+// TrackQuery tracks a SQL query and extracts column changes. A query the
+// parser does not understand is recorded as a single OpUnknown change with
+// ParseError set.
+func (t *SQLTracker) TrackQuery(query string, rowsAffected int, database, oldValue, newValue string) int {
+	return t.track(parseOrFlag(query, rowsAffected, database, oldValue, newValue), rowsAffected, database)
+}
+
+// TrackQueryArgs tracks a query with bound parameters, written with
+// positional "?" or numbered "$N" placeholders. The args are substituted
+// before parsing, so Where and the NewValue of each assigned or inserted
+// column hold the bound values. A placeholder count that does not match
+// len(args) is recorded as a ParseError change.
+func (t *SQLTracker) TrackQueryArgs(query string, args []interface{}, rowsAffected int, database string) int {
+	bound, err := bindArgs(query, args)
+	if err != nil {
+		return t.track(parseErrorChange(query, rowsAffected, database, "", ""), rowsAffected, database)
+	}
+	
+	parsed := parseOrFlag(bound, rowsAffected, database, "", "")
+	fillUpdateValues(parsed, bound)
+	return t.track(parsed, rowsAffected, database)
+}
+
+// track timestamps and records the changes parsed from one query
+func (t *SQLTracker) track(parsed []SQLChange, rowsAffected int, database string) int {
+	if span := t.startSpan(parsed, rowsAffected, database); span != nil {
+		defer span.End()
+	}
+	if len(parsed) == 0 {
+		return 0
+	}
+	
+	t.applyRowCache(parsed)
+	timestamp := t.clock()().UnixNano()
+	for i := range parsed {
+		parsed[i].TimestampNs = timestamp
+	}
+	
+	return t.appendChanges(parsed)
+}
+
+// PreviewQuery returns the changes TrackQuery would record for query,
+// timestamped, filtered, redacted and with old values from the row cache,
+// without storing, persisting or announcing them or updating the row
+// cache. SetDedup is not applied, as it depends on what is tracked next.
+func (t *SQLTracker) PreviewQuery(query string, database string) []SQLChange {
+	parsed := parseOrFlag(query, 0, database, "", "")
+	timestamp := t.clock()().UnixNano()
+	
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	
+	t.fillOldValues(parsed)
+	kept := parsed[:0]
+	for _, change := range parsed {
+		change, ok := t.prepare(change)
+		if !ok {
+			continue
+		}
+		change.TimestampNs = timestamp
+		kept = append(kept, change)
+	}
+	
+	return kept
+}
+
+// QuerySpec bundles the arguments of a single TrackQuery call
+type QuerySpec struct {
+	Query        string
+	RowsAffected int
+	Database     string
+	OldValue     string
+	NewValue     string
+}
+
+// TrackBatch tracks many queries at once. All queries are parsed up front,
+// appended under a single lock and persisted with a single write, which is
+// much cheaper than calling TrackQuery per statement when replaying logs.
+// Returns the total number of changes tracked.
+func (t *SQLTracker) TrackBatch(queries []QuerySpec) int {
+	var parsed []SQLChange
+	now := t.clock()
+	
+	for _, q := range queries {
+		changes := parseOrFlag(q.Query, q.RowsAffected, q.Database, q.OldValue, q.NewValue)
+		t.applyRowCache(changes)
+		timestamp := now().UnixNano()
+		for i := range changes {
+			changes[i].TimestampNs = timestamp
+		}
+		parsed = append(parsed, changes...)
+	}
+	
+	if len(parsed) == 0 {
+		return 0
+	}
+	
+	return t.appendChanges(parsed)
+}
+
+// appendChanges records parsed changes, persists them and notifies callbacks.
+// Returns the number of changes kept after deduplication.
+func (t *SQLTracker) appendChanges(parsed []SQLChange) int {
+	appended := make([]SQLChange, 0, len(parsed))
+	
+	t.mu.Lock()
+	for _, change := range parsed {
+		if change.ParseError {
+			t.parseErrors++
+		}
+		change, ok := t.prepare(change)
+		if !ok {
+			continue
+		}
+		if t.dedup && len(t.changes) > 0 && t.changes[len(t.changes)-1].sameAs(change) {
+			continue
+		}
+		
+		t.changes = append(t.changes, change)
+		appended = append(appended, change)
+	}
+	t.persist(appended)
+	callbacks := t.callbacks
+	t.mu.Unlock()
+	
+	// Callbacks run outside the lock so they may query the tracker
+	for _, change := range appended {
+		for _, cb := range callbacks {
+			cb(change)
+		}
+	}
+	
+	return len(appended)
+}
+
+// prepare applies the column filter, redaction and query length cap to a
+// parsed change, reporting false for a change the tracker drops. t.mu must
+// be held, at least for reading.
+func (t *SQLTracker) prepare(change SQLChange) (SQLChange, bool) {
+	if !t.keeps(change) {
+		return change, false
+	}
+	return t.truncateQuery(t.redact(change)), true
+}
+
+// OnChange registers a callback invoked synchronously for every change
+// appended by TrackQuery, after it has been persisted. Callbacks fire in
+// registration order.
+func (t *SQLTracker) OnChange(cb func(SQLChange)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.callbacks = append(t.callbacks, cb)
+}
+
+// SetDedup enables suppression of a change identical to the one tracked
+// immediately before it. Timestamps are ignored when comparing.
+func (t *SQLTracker) SetDedup(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dedup = enabled
+}
+
+// SetClock replaces the clock used to stamp TimestampNs, so tests can
+// supply a fixed or fake time source. A nil now restores time.Now.
+func (t *SQLTracker) SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+// clock returns the current time source
+func (t *SQLTracker) clock() func() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.now
+}
+
+// SetTrackedColumns restricts tracking to the given columns, each either
+// "table.column" or a bare "column" matching any table, compared without
+// regard to case. Other changes are dropped before they are stored or
+// persisted; unparseable queries are still recorded. An empty cols tracks
+// every column.
+func (t *SQLTracker) SetTrackedColumns(cols []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	t.tracked = nil
+	if len(cols) == 0 {
+		return
+	}
+	
+	t.tracked = make(map[string]bool, len(cols))
+	for _, col := range cols {
+		t.tracked[strings.ToLower(col)] = true
+	}
+}
+
+// keeps reports whether change passes the column allow-list. t.mu must be
+// held for reading.
+func (t *SQLTracker) keeps(change SQLChange) bool {
+	if len(t.tracked) == 0 || change.ParseError {
+		return true
+	}
+	
+	column := strings.ToLower(change.ColumnName)
+	return t.tracked[column] || t.tracked[strings.ToLower(change.TableName)+"."+column]
+}
+
+// SetMaxQueryLength caps the FullQuery stored with each change at n bytes
+// plus a "…" marker, setting QueryTruncated on changes that were cut.
+// Parsing always sees the whole query. 0 means unlimited.
+func (t *SQLTracker) SetMaxQueryLength(n int) {
+	if n < 0 {
+		n = 0
+	}
+	
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maxQuery = n
+}
+
+// truncateQuery applies the FullQuery length cap to change, cutting on a
+// UTF-8 boundary. t.mu must be held for reading.
+func (t *SQLTracker) truncateQuery(change SQLChange) SQLChange {
+	if t.maxQuery == 0 || len(change.FullQuery) <= t.maxQuery {
+		return change
+	}
+	
+	cut := t.maxQuery
+	for cut > 0 && !utf8.RuneStart(change.FullQuery[cut]) {
+		cut--
+	}
+	change.FullQuery = change.FullQuery[:cut] + "…"
+	change.QueryTruncated = true
+	return change
+}
+
+// SetRedaction masks the values of the named columns, matched without
+// regard to case. Changes to those columns have OldValue and NewValue
+// replaced by mask before they are stored, persisted or handed to
+// callbacks, and so do their entries in the Where map. Every change from a
+// query that mentions a redacted column, including those to other columns,
+// has FullQuery and Predicate cleared, as the query text may hold the
+// values. A nil or empty cols disables redaction.
+func (t *SQLTracker) SetRedaction(cols []string, mask string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	t.redacted = nil
+	if len(cols) == 0 {
+		return
+	}
+	
+	t.redacted = make(map[string]bool, len(cols))
+	for _, col := range cols {
+		t.redacted[strings.ToLower(col)] = true
+	}
+	t.mask = mask
+}
+
+// redact applies the redaction settings to change. t.mu must be held for
+// reading.
+func (t *SQLTracker) redact(change SQLChange) SQLChange {
+	if len(t.redacted) == 0 {
+		return change
+	}
+	
+	if t.redacted[strings.ToLower(change.ColumnName)] {
+		change.OldValue = t.mask
+		change.NewValue = t.mask
+	}
+	if t.mentionsRedacted(change.FullQuery) {
+		change.FullQuery = ""
+		change.Predicate = ""
+	}
+	
+	var where map[string]string
+	for column := range change.Where {
+		if !t.redacted[strings.ToLower(column)] {
+			continue
+		}
+		if where == nil {
+			where = make(map[string]string, len(change.Where))
+			for k, v := range change.Where {
+				where[k] = v
+			}
+		}
+		where[column] = t.mask
+	}
+	if where != nil {
+		change.Where = where
+	}
+	
+	return change
+}
+
+// mentionsRedacted reports whether query names a redacted column outside
+// of its string literals, quoted as an identifier or not. t.mu must be held
+// for reading.
+func (t *SQLTracker) mentionsRedacted(query string) bool {
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'':
+			i = skipQuoted(query, i) - 1
+		case isIdentByte(c):
+			word := leadingWord(query[i:])
+			if t.redacted[strings.ToLower(word)] {
+				return true
+			}
+			i += len(word) - 1
+		}
+	}
+	return false
+}
+
+// ParseErrors returns how many tracked queries the parser did not understand
+func (t *SQLTracker) ParseErrors() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.parseErrors
+}
+
+// sameAs reports whether two changes describe the same column mutation
+func (c SQLChange) sameAs(other SQLChange) bool {
+	return c.TableName == other.TableName &&
+		c.ColumnName == other.ColumnName &&
+		c.Operation == other.Operation &&
+		c.OldValue == other.OldValue &&
+		c.NewValue == other.NewValue
+}
+
+// persist hands changes to the storage backend, if one is configured,
+// as a single batch when the backend supports it. A failed write is
+// counted and kept for PersistErrors and LastPersistError; the changes
+// stay in memory either way. t.mu must be held.
+func (t *SQLTracker) persist(changes []SQLChange) {
+	if t.storage == nil || len(changes) == 0 {
+		return
+	}
+	
+	if batch, ok := t.storage.(batchAppender); ok {
+		if err := batch.AppendBatch(changes); err != nil {
+			t.persistFailed(err)
+		}
+		return
+	}
+	for _, change := range changes {
+		if err := t.storage.Append(change); err != nil {
+			t.persistFailed(err)
+			return
+		}
+	}
+}
+
+// persistFailed records a storage write error. t.mu must be held.
+func (t *SQLTracker) persistFailed(err error) {
+	t.persistErrs++
+	t.persistErr = err
+}
+
+// PersistErrors returns how many writes to the storage backend failed.
+// The changes of a failed write are still held in memory but may be
+// missing from storage.
+func (t *SQLTracker) PersistErrors() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.persistErrs
+}
+
+// LastPersistError returns the error of the most recent failed write to
+// the storage backend, or nil if none has failed.
+func (t *SQLTracker) LastPersistError() error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.persistErr
+}
+
+// GetChanges returns changes filtered by criteria
+func (t *SQLTracker) GetChanges(tableFilter, columnFilter, operationFilter string) []SQLChange {
+	return t.getChanges(tableFilter, columnFilter, operationFilter, false)
+}
+
+// GetChangesExcludingNoOps is GetChanges without the UPDATEs that set a
+// column to the value it already had
+func (t *SQLTracker) GetChangesExcludingNoOps(tableFilter, columnFilter, operationFilter string) []SQLChange {
+	return t.getChanges(tableFilter, columnFilter, operationFilter, true)
+}
+
+func (t *SQLTracker) getChanges(tableFilter, columnFilter, operationFilter string, skipNoOps bool) []SQLChange {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	
+	var result []SQLChange
+	
+	for _, change := range t.changes {
+		match := !(skipNoOps && change.NoOp)
+		
+		if tableFilter != "" && change.TableName != tableFilter {
+			match = false
+		}
+		if columnFilter != "" && change.ColumnName != columnFilter {
+			match = false
+		}
+		if operationFilter != "" && operationName(change.Operation) != operationFilter {
+			match = false
+		}
+		
+		if match {
+			result = append(result, change)
+		}
+	}
+	
+	return result
+}
+
+// Iterate calls f for each tracked change in tracking order until f returns
+// false. Unlike GetChanges it copies nothing, but it holds the read lock
+// throughout, so f must not track queries or change the configuration.
+func (t *SQLTracker) Iterate(f func(SQLChange) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	
+	for _, change := range t.changes {
+		if !f(change) {
+			return
+		}
+	}
+}
+
+// Summary returns summary statistics
+type Summary struct {
+	TotalChanges int
+	Insert       int
+	Update       int
+	Delete       int
+	Select       int
+	Create       int
+	Alter        int
+	Drop         int
+	Truncate     int
+	Tables       map[string]int
+	Columns      []string
+}
+
+// GetSummary returns statistics about tracked changes
+func (t *SQLTracker) GetSummary() *Summary {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	
+	summary := &Summary{
+		TotalChanges: len(t.changes),
+		Tables:       make(map[string]int),
+		Columns:      make([]string, 0),
+	}
+	
+	columnMap := make(map[string]bool)
+	
+	for _, change := range t.changes {
+		switch change.Operation {
+		case OpInsert:
+			summary.Insert++
+		case OpUpdate:
+			summary.Update++
+		case OpDelete:
+			summary.Delete++
+		case OpSelect:
+			summary.Select++
+		case OpCreate:
+			summary.Create++
+		case OpAlter:
+			summary.Alter++
+		case OpDrop:
+			summary.Drop++
+		case OpTruncate:
+			summary.Truncate++
+		}
+		
+		summary.Tables[change.TableName]++
+		
+		// DDL changes name an object but no column
+		if change.ColumnName == "" {
+			continue
+		}
+		colKey := change.TableName + "." + change.ColumnName
+		if !columnMap[colKey] {
+			columnMap[colKey] = true
+			summary.Columns = append(summary.Columns, colKey)
+		}
+	}
+	
+	return summary
+}
+
+// MergeSummaries combines summaries of separate trackers: operation counts
+// and per-table counts are added and the column lists are united, sorted
+// and deduplicated. Nil summaries are skipped.
+func MergeSummaries(s ...*Summary) *Summary {
+	merged := &Summary{
+		Tables:  make(map[string]int),
+		Columns: make([]string, 0),
+	}
+	
+	seen := make(map[string]bool)
+	for _, summary := range s {
+		if summary == nil {
+			continue
+		}
+		
+		merged.TotalChanges += summary.TotalChanges
+		merged.Insert += summary.Insert
+		merged.Update += summary.Update
+		merged.Delete += summary.Delete
+		merged.Select += summary.Select
+		merged.Create += summary.Create
+		merged.Alter += summary.Alter
+		merged.Drop += summary.Drop
+		merged.Truncate += summary.Truncate
+		
+		for table, n := range summary.Tables {
+			merged.Tables[table] += n
+		}
+		for _, column := range summary.Columns {
+			if !seen[column] {
+				seen[column] = true
+				merged.Columns = append(merged.Columns, column)
+			}
+		}
+	}
+	
+	sort.Strings(merged.Columns)
+	return merged
+}
+
+// SummaryDelta is the difference between two summaries: counts are after
+// minus before
+type SummaryDelta struct {
+	TotalChanges   int
+	Insert         int
+	Update         int
+	Delete         int
+	Select         int
+	Create         int
+	Alter          int
+	Drop           int
+	Truncate       int
+	Tables         map[string]int
+	NewTables      []string
+	GoneTables     []string
+	AddedColumns   []string
+	RemovedColumns []string
+}
+
+// DiffSummaries compares two summaries, e.g. yesterday's and today's.
+// Tables holds the count change of every table whose count changed,
+// including tables only one summary has; those are also listed in
+// NewTables or GoneTables. The table and column lists are sorted. A nil
+// summary counts as empty.
+func DiffSummaries(before, after *Summary) *SummaryDelta {
+	if before == nil {
+		before = &Summary{}
+	}
+	if after == nil {
+		after = &Summary{}
+	}
+	
+	delta := &SummaryDelta{
+		TotalChanges:   after.TotalChanges - before.TotalChanges,
+		Insert:         after.Insert - before.Insert,
+		Update:         after.Update - before.Update,
+		Delete:         after.Delete - before.Delete,
+		Select:         after.Select - before.Select,
+		Create:         after.Create - before.Create,
+		Alter:          after.Alter - before.Alter,
+		Drop:           after.Drop - before.Drop,
+		Truncate:       after.Truncate - before.Truncate,
+		Tables:         make(map[string]int),
+		NewTables:      make([]string, 0),
+		GoneTables:     make([]string, 0),
+		AddedColumns:   make([]string, 0),
+		RemovedColumns: make([]string, 0),
+	}
+	
+	for table, n := range after.Tables {
+		if _, ok := before.Tables[table]; !ok {
+			delta.NewTables = append(delta.NewTables, table)
+		}
+		if d := n - before.Tables[table]; d != 0 {
+			delta.Tables[table] = d
+		}
+	}
+	for table, n := range before.Tables {
+		if _, ok := after.Tables[table]; !ok {
+			delta.GoneTables = append(delta.GoneTables, table)
+			delta.Tables[table] = -n
+		}
+	}
+	
+	beforeColumns := make(map[string]bool, len(before.Columns))
+	for _, column := range before.Columns {
+		beforeColumns[column] = true
+	}
+	afterColumns := make(map[string]bool, len(after.Columns))
+	for _, column := range after.Columns {
+		afterColumns[column] = true
+		if !beforeColumns[column] {
+			delta.AddedColumns = append(delta.AddedColumns, column)
+		}
+	}
+	for _, column := range before.Columns {
+		if !afterColumns[column] {
+			delta.RemovedColumns = append(delta.RemovedColumns, column)
+		}
+	}
+	
+	sort.Strings(delta.NewTables)
+	sort.Strings(delta.GoneTables)
+	sort.Strings(delta.AddedColumns)
+	sort.Strings(delta.RemovedColumns)
+	return delta
+}
+
+// Bucket counts the changes whose timestamps fall in one histogram interval
+type Bucket struct {
+	Start time.Time
+	Count int
+	ByOp  map[int]int
+}
+
+// Histogram counts the tracked changes per interval of length bucket,
+// e.g. time.Minute. Each change falls in the bucket its TimestampNs
+// truncates to; buckets are sorted by Start and intervals without changes
+// are omitted. A non-positive bucket yields nil.
+func (t *SQLTracker) Histogram(bucket time.Duration) []Bucket {
+	if bucket <= 0 {
+		return nil
+	}
+	
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	
+	index := make(map[int64]int)
+	var buckets []Bucket
+	for _, change := range t.changes {
+		start := time.Unix(0, change.TimestampNs).Truncate(bucket)
+		i, ok := index[start.UnixNano()]
+		if !ok {
+			i = len(buckets)
+			index[start.UnixNano()] = i
+			buckets = append(buckets, Bucket{Start: start, ByOp: make(map[int]int)})
+		}
+		buckets[i].Count++
+		buckets[i].ByOp[change.Operation]++
+	}
+	
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+	return buckets
+}
+
+// Close frees the tracker
+func (t *SQLTracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	if t.tracker != nil {
+		// C.sql_tracker_free(t.tracker)
+		t.tracker = nil
+	}
+	if t.storage != nil {
+		t.storage.Close()
+		t.storage = nil
+	}
+}
+
+// Global tracker instance
+var (
+	globalTracker *SQLTracker
+	globalMu      sync.Mutex
+)
+
+// Init initializes the global tracker
+func Init(storagePath string) *SQLTracker {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	
+	if globalTracker != nil {
+		globalTracker.Close()
+	}
+	globalTracker = New(storagePath)
+	return globalTracker
+}
+
+// Get returns the global tracker
+func Get() *SQLTracker {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	
+	if globalTracker == nil {
+		globalTracker = New("")
+	}
+	return globalTracker
+}
+
+// TrackQuery uses the global tracker
+func TrackQuery(query string, rowsAffected int, database, oldValue, newValue string) int {
+	return Get().TrackQuery(query, rowsAffected, database, oldValue, newValue)
+}
+
+// Helper function to convert operation code to string
+func operationName(op int) string {
+	switch op {
+	case OpInsert:
+		return "INSERT"
+	case OpUpdate:
+		return "UPDATE"
+	case OpDelete:
+		return "DELETE"
+	case OpSelect:
+		return "SELECT"
+	case OpCreate:
+		return "CREATE"
+	case OpAlter:
+		return "ALTER"
+	case OpDrop:
+		return "DROP"
+	case OpTruncate:
+		return "TRUNCATE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// QueryMentions reports whether term occurs anywhere in query, ignoring
+// case. An empty term never matches.
+func QueryMentions(query, term string) bool {
+	if term == "" {
+		return false
+	}
+	// Lowering can change byte lengths, so compare only the lowered forms
+	return strings.Contains(strings.ToLower(query), strings.ToLower(term))
+}
+
+// QueryMentionsExact is QueryMentions with case taken into account, e.g.
+// to tell a quoted "Email" column from email. An empty term never matches.
+func QueryMentionsExact(query, term string) bool {
+	return term != "" && strings.Contains(query, term)
+}
+
+// Example usage (uncomment to test):
+/*
+func main() {
+	tracker := New("/tmp/sql_changes.jsonl")
+	defer tracker.Close()
+	
+	tracker.TrackQuery("INSERT INTO users (name, email) VALUES ('Alice', 'alice@example.com')", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'new@example.com' WHERE id = 1", 1, "mydb", "old@example.com", "new@example.com")
+	
+	summary := tracker.GetSummary()
+	println("Total changes:", summary.TotalChanges)
+}
+*/
@@ -0,0 +1,106 @@
+package sqltracker
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bindArgs substitutes args for the placeholders of query, which may use
+// positional "?" or numbered "$N" placeholders but not both. Placeholders
+// inside string literals and quoted identifiers are left alone. It fails
+// when the placeholders do not use exactly len(args) arguments.
+func bindArgs(query string, args []interface{}) (string, error) {
+	var b strings.Builder
+	positional, maxNumbered := 0, 0
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(query, i)
+			b.WriteString(query[i:end])
+			i = end - 1
+		case c == '?':
+			if positional < len(args) {
+				b.WriteString(sqlLiteral(args[positional]))
+			}
+			positional++
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			end := i + 1
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			n, _ := strconv.Atoi(query[i+1 : end])
+			if n > maxNumbered {
+				maxNumbered = n
+			}
+			if n >= 1 && n <= len(args) {
+				b.WriteString(sqlLiteral(args[n-1]))
+			}
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	switch {
+	case positional > 0 && maxNumbered > 0:
+		return "", fmt.Errorf("query mixes ? and $N placeholders")
+	case positional+maxNumbered != len(args):
+		return "", fmt.Errorf("query has %d placeholders for %d args", positional+maxNumbered, len(args))
+	}
+	return b.String(), nil
+}
+
+// sqlLiteral renders a bound argument as the SQL literal the parser would
+// see had it been written inline
+func sqlLiteral(arg interface{}) string {
+	if valuer, ok := arg.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			arg = v
+		}
+	}
+
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case []byte:
+		return quoteLiteral(string(v))
+	case time.Time:
+		return quoteLiteral(v.Format(time.RFC3339Nano))
+	default:
+		return quoteLiteral(fmt.Sprint(v))
+	}
+}
+
+// quoteLiteral quotes s as a SQL string literal, the inverse of
+// unquoteLiteral
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// fillUpdateValues sets the NewValue of each UPDATE change to the value
+// assigned to its column in query, when that value is a literal. Columns
+// assigned an expression keep an empty NewValue.
+func fillUpdateValues(changes []SQLChange, query string) {
+	values := assignedLiterals(normalizeQuery(skipSpaceAndComments(query)))
+
+	for i := range changes {
+		if changes[i].Operation != OpUpdate || changes[i].ParseError {
+			continue
+		}
+		if value, ok := values[changes[i].ColumnName]; ok {
+			changes[i].NewValue = value
+		}
+	}
+}
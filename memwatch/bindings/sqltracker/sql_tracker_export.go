@@ -0,0 +1,83 @@
+package sqltracker
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// csvColumns lists every exportable SQLChange field in default order
+var csvColumns = []string{
+	"timestamp",
+	"table",
+	"column",
+	"operation",
+	"old_value",
+	"new_value",
+	"rows_affected",
+	"database",
+	"full_query",
+}
+
+// csvField renders a single named field of a change
+func csvField(change SQLChange, col string) (string, error) {
+	switch col {
+	case "timestamp":
+		return strconv.FormatInt(change.TimestampNs, 10), nil
+	case "table":
+		return change.TableName, nil
+	case "column":
+		return change.ColumnName, nil
+	case "operation":
+		return operationName(change.Operation), nil
+	case "old_value":
+		return change.OldValue, nil
+	case "new_value":
+		return change.NewValue, nil
+	case "rows_affected":
+		return strconv.Itoa(change.RowsAffected), nil
+	case "database":
+		return change.Database, nil
+	case "full_query":
+		return change.FullQuery, nil
+	default:
+		return "", fmt.Errorf("unknown CSV column: %q", col)
+	}
+}
+
+// ExportCSV writes a header row followed by one row per tracked change.
+// cols selects the fields and their order; an empty cols exports all fields.
+func (t *SQLTracker) ExportCSV(w io.Writer, cols []string) error {
+	if len(cols) == 0 {
+		cols = csvColumns
+	}
+
+	for _, col := range cols {
+		if _, err := csvField(SQLChange{}, col); err != nil {
+			return err
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(cols); err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	changes := append([]SQLChange(nil), t.changes...)
+	t.mu.RUnlock()
+
+	row := make([]string, len(cols))
+	for _, change := range changes {
+		for i, col := range cols {
+			row[i], _ = csvField(change, col)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,158 @@
+package sqltracker
+
+import (
+	"strings"
+)
+
+// fingerprintKeywords are upper-cased in fingerprints so keyword case does
+// not split otherwise identical statements
+var fingerprintKeywords = map[string]bool{
+	"ALL": true, "AND": true, "AS": true, "ASC": true, "BETWEEN": true,
+	"BY": true, "CASE": true, "DELETE": true, "DESC": true, "DISTINCT": true,
+	"ELSE": true, "END": true, "EXISTS": true, "FROM": true, "GROUP": true,
+	"HAVING": true, "IN": true, "INNER": true, "INSERT": true, "INTO": true,
+	"IS": true, "JOIN": true, "LEFT": true, "LIKE": true, "LIMIT": true,
+	"NOT": true, "NULL": true, "OFFSET": true, "ON": true, "OR": true,
+	"ORDER": true, "OUTER": true, "RETURNING": true, "RIGHT": true,
+	"SELECT": true, "SET": true, "THEN": true, "UNION": true, "UPDATE": true,
+	"VALUES": true, "WHEN": true, "WHERE": true, "WITH": true,
+}
+
+// Fingerprint returns the shape of query: string and numeric literals
+// become "?", IN lists collapse to "IN (?)", keywords are upper-cased,
+// comments are dropped and whitespace is normalized. Queries differing only
+// in literal values share a fingerprint.
+func Fingerprint(query string) string {
+	tokens := fingerprintTokens(query)
+	tokens = collapseInLists(tokens)
+
+	var b strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && needsSpace(tokens[i-1], tok) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(tok)
+	}
+
+	return b.String()
+}
+
+// fingerprintTokens splits a query into words, placeholders, quoted
+// identifiers and punctuation, dropping whitespace and comments
+func fingerprintTokens(query string) []string {
+	var tokens []string
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.IndexByte(" \t\n\r\f\v", c) >= 0:
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'':
+			i = skipQuoted(query, i)
+			tokens = append(tokens, "?")
+		case c == '"' || c == '`':
+			end := skipQuoted(query, i)
+			tokens = append(tokens, query[i:end])
+			i = end
+		case c >= '0' && c <= '9':
+			for i < len(query) && (isIdentByte(query[i]) || query[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, "?")
+		case isIdentByte(c):
+			word := leadingWord(query[i:])
+			if upper := upperASCII(word); fingerprintKeywords[upper] {
+				word = upper
+			}
+			tokens = append(tokens, word)
+			i += len(word)
+		default:
+			// Keep multi-byte operators such as <=, <> and != together
+			end := i + 1
+			for end < len(query) && strings.IndexByte("<>=!", query[end]) >= 0 && strings.IndexByte("<>=!", c) >= 0 {
+				end++
+			}
+			tokens = append(tokens, query[i:end])
+			i = end
+		}
+	}
+
+	return tokens
+}
+
+// skipQuoted returns the index just past the quoted literal starting at i.
+// A doubled quote, as in 'it''s', is part of the literal.
+func skipQuoted(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		if s[j] != quote || s[j-1] == '\\' {
+			continue
+		}
+		if j+1 < len(s) && s[j+1] == quote {
+			j++
+			continue
+		}
+		return j + 1
+	}
+	return len(s)
+}
+
+// collapseInLists rewrites "IN ( ? , ? , ... )" to "IN ( ? )"
+func collapseInLists(tokens []string) []string {
+	out := tokens[:0:0]
+	for i := 0; i < len(tokens); i++ {
+		out = append(out, tokens[i])
+		if tokens[i] != "IN" || i+2 >= len(tokens) || tokens[i+1] != "(" {
+			continue
+		}
+
+		j := i + 2
+		for j+1 < len(tokens) && tokens[j] == "?" && tokens[j+1] == "," {
+			j += 2
+		}
+		if j+1 < len(tokens) && tokens[j] == "?" && tokens[j+1] == ")" {
+			out = append(out, "(", "?", ")")
+			i = j + 1
+		}
+	}
+	return out
+}
+
+// needsSpace reports whether a space separates tokens prev and next
+func needsSpace(prev, next string) bool {
+	switch {
+	case prev == "(" || prev == ".":
+		return false
+	case next == ")" || next == "," || next == "." || next == ";":
+		return false
+	case next == "(" && !fingerprintKeywords[prev]:
+		// Function calls and column lists: count(*), users(a, b)
+		return false
+	}
+	return true
+}
+
+// GroupByFingerprint groups the tracked changes by the fingerprint of the
+// query that produced them, keeping tracking order within each group
+func (t *SQLTracker) GroupByFingerprint() map[string][]SQLChange {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	groups := make(map[string][]SQLChange)
+	for _, change := range t.changes {
+		groups[change.Fingerprint] = append(groups[change.Fingerprint], change)
+	}
+	return groups
+}
@@ -0,0 +1,112 @@
+package sqltracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sqlChangeFields is SQLChange without its JSON methods, so they can
+// delegate the remaining fields to the default encoding
+type sqlChangeFields SQLChange
+
+// MarshalJSON encodes the change with its operation spelled out, e.g.
+// "UPDATE", so stored lines are readable without the Op constants
+func (c SQLChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		sqlChangeFields
+		Operation string `json:"operation"`
+	}{sqlChangeFields(c), operationName(c.Operation)})
+}
+
+// UnmarshalJSON decodes a change whose operation is either a name such as
+// "UPDATE" or the numeric Op code written by older versions. Lines written
+// before the snake_case field names, with keys such as "TableName", are
+// still understood.
+func (c *SQLChange) UnmarshalJSON(b []byte) error {
+	b, err := snakeCaseKeys(b)
+	if err != nil {
+		return err
+	}
+
+	aux := struct {
+		*sqlChangeFields
+		Operation json.RawMessage `json:"operation"`
+	}{sqlChangeFields: (*sqlChangeFields)(c)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Operation) == 0 || bytes.Equal(aux.Operation, []byte("null")) {
+		return nil
+	}
+	if aux.Operation[0] != '"' {
+		return json.Unmarshal(aux.Operation, &c.Operation)
+	}
+
+	var name string
+	if err := json.Unmarshal(aux.Operation, &name); err != nil {
+		return err
+	}
+	op, ok := operationCode(name)
+	if !ok {
+		return fmt.Errorf("unknown operation: %q", name)
+	}
+	c.Operation = op
+	return nil
+}
+
+// snakeCaseKeys rewrites the legacy Go field name keys of a JSON object,
+// such as "RowsAffected", to their snake_case form. Objects already using
+// snake_case keys are returned unchanged.
+func snakeCaseKeys(b []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil || fields == nil {
+		return b, err
+	}
+
+	legacy := false
+	for key := range fields {
+		if key != strings.ToLower(key) {
+			legacy = true
+			break
+		}
+	}
+	if !legacy {
+		return b, nil
+	}
+
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for key, value := range fields {
+		renamed[snakeCase(key)] = value
+	}
+	return json.Marshal(renamed)
+}
+
+// snakeCase converts a Go field name such as "TimestampNs" to
+// "timestamp_ns"
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// operationCode is the inverse of operationName
+func operationCode(name string) (int, bool) {
+	for _, op := range []int{OpUnknown, OpInsert, OpUpdate, OpDelete, OpSelect, OpCreate, OpAlter, OpDrop, OpTruncate} {
+		if operationName(op) == name {
+			return op, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package sqltracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonPatchOp is a single RFC 6902 operation
+type jsonPatchOp struct {
+	Op    string  `json:"op"`
+	Path  string  `json:"path"`
+	Value *string `json:"value,omitempty"`
+}
+
+// pointerEscaper escapes a JSON Pointer reference token (RFC 6901)
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// patchOp maps the change to a patch operation. An UPDATE replaces
+// /table/column, an INSERT adds it and a DELETE removes it; other
+// operations have no JSON Patch form.
+func (c SQLChange) patchOp() (jsonPatchOp, error) {
+	var op jsonPatchOp
+	switch c.Operation {
+	case OpUpdate:
+		op.Op = "replace"
+	case OpInsert:
+		op.Op = "add"
+	case OpDelete:
+		op.Op = "remove"
+	default:
+		return op, fmt.Errorf("no JSON Patch operation for %s", operationName(c.Operation))
+	}
+
+	// A whole-row change such as a DELETE has column "*" and targets the
+	// table itself
+	op.Path = "/" + pointerEscaper.Replace(c.TableName)
+	if c.ColumnName != "" && c.ColumnName != "*" {
+		op.Path += "/" + pointerEscaper.Replace(c.ColumnName)
+	}
+
+	if op.Op != "remove" {
+		value := c.NewValue
+		op.Value = &value
+	}
+	return op, nil
+}
+
+// ToJSONPatch renders the change as an RFC 6902 JSON Patch document holding
+// one operation. The value of an add or replace is NewValue as a string.
+func (c SQLChange) ToJSONPatch() ([]byte, error) {
+	op, err := c.patchOp()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal([]jsonPatchOp{op})
+}
+
+// ChangesToJSONPatch renders changes, in order, as a single JSON Patch
+// document. It fails on the first change that is not an INSERT, UPDATE or
+// DELETE.
+func ChangesToJSONPatch(changes []SQLChange) ([]byte, error) {
+	ops := make([]jsonPatchOp, 0, len(changes))
+	for _, change := range changes {
+		op, err := change.patchOp()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return json.Marshal(ops)
+}
@@ -0,0 +1,58 @@
+package sqltracker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	changesDesc = prometheus.NewDesc(
+		"sqltracker_changes_total",
+		"Total number of tracked column changes.",
+		nil, nil,
+	)
+	operationsDesc = prometheus.NewDesc(
+		"sqltracker_operation_changes_total",
+		"Number of tracked column changes per SQL operation.",
+		[]string{"operation"}, nil,
+	)
+	tablesDesc = prometheus.NewDesc(
+		"sqltracker_tables",
+		"Number of distinct tables touched by tracked changes.",
+		nil, nil,
+	)
+)
+
+// sqlCollector exposes a tracker's summary as Prometheus metrics
+type sqlCollector struct {
+	tracker *SQLTracker
+}
+
+// PrometheusCollector returns a collector reporting the same counts as
+// GetSummary. Values are computed at scrape time under the tracker's read
+// lock, so it is safe to scrape while TrackQuery is running.
+func (t *SQLTracker) PrometheusCollector() prometheus.Collector {
+	return &sqlCollector{tracker: t}
+}
+
+// Describe implements prometheus.Collector
+func (c *sqlCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- changesDesc
+	ch <- operationsDesc
+	ch <- tablesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *sqlCollector) Collect(ch chan<- prometheus.Metric) {
+	summary := c.tracker.GetSummary()
+
+	ch <- prometheus.MustNewConstMetric(changesDesc, prometheus.CounterValue, float64(summary.TotalChanges))
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Insert), "insert")
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Update), "update")
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Delete), "delete")
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Select), "select")
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Create), "create")
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Alter), "alter")
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Drop), "drop")
+	ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(summary.Truncate), "truncate")
+	ch <- prometheus.MustNewConstMetric(tablesDesc, prometheus.GaugeValue, float64(len(summary.Tables)))
+}
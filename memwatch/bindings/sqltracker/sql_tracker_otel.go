@@ -0,0 +1,41 @@
+package sqltracker
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetTracer makes TrackQuery emit one span per tracked query, named after
+// the SQL operation. A nil tracer disables tracing.
+func (t *SQLTracker) SetTracer(tr trace.Tracer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tracer = tr
+}
+
+// startSpan starts the span for a tracked query, or returns nil when no
+// tracer is set
+func (t *SQLTracker) startSpan(parsed []SQLChange, rowsAffected int, database string) trace.Span {
+	t.mu.RLock()
+	tr := t.tracer
+	t.mu.RUnlock()
+
+	if tr == nil {
+		return nil
+	}
+
+	op, table := OpUnknown, ""
+	if len(parsed) > 0 {
+		op, table = parsed[0].Operation, parsed[0].TableName
+	}
+
+	_, span := tr.Start(context.Background(), operationName(op), trace.WithAttributes(
+		attribute.String("db.sql.table", table),
+		attribute.Int("sqltracker.column_count", len(parsed)),
+		attribute.Int("sqltracker.rows_affected", rowsAffected),
+		attribute.String("db.name", database),
+	))
+	return span
+}
@@ -0,0 +1,867 @@
+package sqltracker
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Pure-Go port of the query analysis in src/sql_tracker.c, so the binding
+// can extract column changes without loading the native library.
+
+// parseQuery extracts one SQLChange per affected column. It returns nil when
+// the query could not be understood, matching sql_tracker_track_query.
+func parseQuery(query string, rowsAffected int, database, oldValue, newValue string) []SQLChange {
+	// Leading comments go first, as normalizing would join a "--" comment
+	// with the statement on the following line
+	normalized := normalizeQuery(skipSpaceAndComments(query))
+	if normalized == "" {
+		return nil
+	}
+
+	op := ClassifyOperation(normalized)
+	if op == OpUnknown {
+		return nil
+	}
+
+	if isDDL(op) {
+		object := extractObjectName(normalized)
+		if object == "" {
+			return nil
+		}
+		return []SQLChange{{
+			TableName:    object,
+			Operation:    op,
+			OldValue:     oldValue,
+			NewValue:     newValue,
+			RowsAffected: rowsAffected,
+			Database:     database,
+			FullQuery:    normalized,
+		}}
+	}
+
+	// The common table expressions of a WITH statement are only read from,
+	// so everything but FullQuery comes from the main statement
+	stmt := mainStatement(normalized)
+
+	table := extractTableName(stmt, op)
+	if table == "" {
+		return nil
+	}
+
+	if op == OpInsert {
+		if source := insertSelectSource(stmt); source != "" {
+			return []SQLChange{{
+				TableName:    table,
+				ColumnName:   "*",
+				Operation:    OpInsert,
+				OldValue:     oldValue,
+				NewValue:     newValue,
+				RowsAffected: rowsAffected,
+				Database:     database,
+				FullQuery:    normalized,
+				SourceTables: extractSourceTables(source),
+			}}
+		}
+	}
+
+	var columns []string
+	switch op {
+	case OpUpdate:
+		columns = extractUpdateColumns(stmt)
+	case OpInsert:
+		columns = extractInsertColumns(stmt)
+	case OpSelect:
+		columns = extractSelectColumns(stmt)
+	case OpDelete:
+		columns = []string{"*"}
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	clause := whereClause(stmt)
+	where := parseWhere(clause)
+
+	if op == OpInsert {
+		if tuples := extractInsertTuples(stmt); len(tuples) > 0 {
+			return insertChanges(tuples, columns, table, rowsAffected, database, oldValue, newValue, normalized)
+		}
+	}
+
+	// Only a single-column UPDATE can be matched with the supplied values
+	noOp := op == OpUpdate && len(columns) == 1 && oldValue != "" && oldValue == newValue
+
+	// DELETEs keep their predicate for auditing; one without a WHERE
+	// clause empties the whole table
+	var predicate string
+	if op == OpDelete {
+		predicate = clause
+	}
+
+	var assigned map[string]string
+	if op == OpUpdate {
+		assigned = make(map[string]string)
+		for _, assignment := range extractUpdateAssignments(stmt) {
+			assigned[assignment[0]] = assignment[1]
+		}
+	}
+
+	changes := make([]SQLChange, 0, len(columns))
+	for _, column := range columns {
+		changes = append(changes, SQLChange{
+			TableName:       table,
+			ColumnName:      column,
+			Operation:       op,
+			OldValue:        oldValue,
+			NewValue:        newValue,
+			RowsAffected:    rowsAffected,
+			Database:        database,
+			FullQuery:       normalized,
+			Where:           where,
+			NoOp:            noOp,
+			Predicate:       predicate,
+			FullTableDelete: op == OpDelete && predicate == "",
+			InferredType:    inferType(assigned[column]),
+		})
+	}
+
+	return changes
+}
+
+// parseOrFlag is parseQuery, except that a non-empty query the parser does
+// not understand yields a single OpUnknown change flagged with ParseError
+// instead of nothing.
+func parseOrFlag(query string, rowsAffected int, database, oldValue, newValue string) []SQLChange {
+	if changes := parseQuery(query, rowsAffected, database, oldValue, newValue); len(changes) > 0 {
+		fingerprint := Fingerprint(query)
+		for i := range changes {
+			changes[i].Fingerprint = fingerprint
+		}
+		return changes
+	}
+	return parseErrorChange(query, rowsAffected, database, oldValue, newValue)
+}
+
+// parseErrorChange records query as a single OpUnknown change flagged with
+// ParseError. An empty query yields nothing.
+func parseErrorChange(query string, rowsAffected int, database, oldValue, newValue string) []SQLChange {
+	normalized := normalizeQuery(query)
+	if normalized == "" {
+		return nil
+	}
+
+	return []SQLChange{{
+		Operation:    OpUnknown,
+		OldValue:     oldValue,
+		NewValue:     newValue,
+		RowsAffected: rowsAffected,
+		Database:     database,
+		FullQuery:    normalized,
+		ParseError:   true,
+		Fingerprint:  Fingerprint(query),
+	}}
+}
+
+// normalizeQuery trims the query and collapses whitespace outside of string
+// literals into single spaces.
+func normalizeQuery(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		if quote != 0 {
+			b.WriteByte(c)
+			if c == quote && query[i-1] != '\\' {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+			b.WriteByte(c)
+		case ' ', '\t', '\n', '\r', '\f', '\v':
+			s := b.String()
+			if len(s) > 0 && s[len(s)-1] != ' ' {
+				b.WriteByte(' ')
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// ClassifyOperation returns the operation of query from its leading
+// keyword, ignoring case, leading whitespace and comments. For a WITH
+// statement the operation is that of the main statement following the
+// common table expressions. Anything else is OpUnknown.
+func ClassifyOperation(query string) int {
+	return keywordOperation(leadingWord(mainStatement(query)))
+}
+
+// mainStatement returns query from the keyword of its main statement on,
+// skipping leading whitespace and comments and, for a WITH statement, the
+// common table expressions. It returns "" when a WITH has no main
+// statement.
+func mainStatement(query string) string {
+	query = skipSpaceAndComments(query)
+
+	word := leadingWord(query)
+	if upperASCII(word) != "WITH" {
+		return query
+	}
+
+	// CTE bodies are parenthesized, so the first DML keyword at depth 0
+	// belongs to the main statement
+	var quote byte
+	depth := 0
+	for i := len(word); i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			if c == quote && query[i-1] != '\\' {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && isIdentByte(c) && !isIdentByte(query[i-1]):
+			word := leadingWord(query[i:])
+			if keywordOperation(word) != OpUnknown {
+				return query[i:]
+			}
+			i += len(word) - 1
+		}
+	}
+
+	return ""
+}
+
+// keywordOperation maps a DML keyword to its operation
+func keywordOperation(word string) int {
+	switch upperASCII(word) {
+	case "INSERT":
+		return OpInsert
+	case "UPDATE":
+		return OpUpdate
+	case "DELETE":
+		return OpDelete
+	case "SELECT":
+		return OpSelect
+	case "CREATE":
+		return OpCreate
+	case "ALTER":
+		return OpAlter
+	case "DROP":
+		return OpDrop
+	case "TRUNCATE":
+		return OpTruncate
+	}
+	return OpUnknown
+}
+
+// leadingWord returns the identifier characters at the start of s
+func leadingWord(s string) string {
+	end := 0
+	for end < len(s) && isIdentByte(s[end]) {
+		end++
+	}
+	return s[:end]
+}
+
+// skipSpaceAndComments drops leading whitespace, "--" line comments and
+// "/* */" block comments.
+func skipSpaceAndComments(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f\v")
+		switch {
+		case strings.HasPrefix(s, "--"):
+			end := strings.IndexByte(s, '\n')
+			if end < 0 {
+				return ""
+			}
+			s = s[end+1:]
+		case strings.HasPrefix(s, "/*"):
+			end := strings.Index(s[2:], "*/")
+			if end < 0 {
+				return ""
+			}
+			s = s[end+4:]
+		default:
+			return s
+		}
+	}
+}
+
+// extractTableName returns the identifier following the keyword that names
+// the target table for op, with any identifier quoting removed.
+func extractTableName(query string, op int) string {
+	var keyword string
+	switch op {
+	case OpInsert:
+		keyword = "INSERT INTO"
+	case OpUpdate:
+		keyword = "UPDATE"
+	case OpDelete, OpSelect:
+		keyword = "FROM"
+	default:
+		return ""
+	}
+
+	pos := indexKeyword(query, keyword)
+	if pos < 0 {
+		return ""
+	}
+
+	rest := strings.TrimLeft(query[pos+len(keyword):], " ")
+
+	var b strings.Builder
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		if c == ' ' || c == '(' || c == ';' {
+			break
+		}
+		if c == '`' || c == '"' || c == '\'' {
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// isDDL reports whether op changes a schema object rather than rows
+func isDDL(op int) bool {
+	return op == OpCreate || op == OpAlter || op == OpDrop || op == OpTruncate
+}
+
+// ddlModifiers are the words between a DDL keyword and the object name,
+// as in "CREATE OR REPLACE VIEW" or "DROP TABLE IF EXISTS"
+var ddlModifiers = map[string]bool{
+	"OR": true, "REPLACE": true, "TEMP": true, "TEMPORARY": true,
+	"UNIQUE": true, "MATERIALIZED": true, "IF": true, "NOT": true,
+	"EXISTS": true, "ONLY": true, "TABLE": true, "INDEX": true,
+	"VIEW": true, "SCHEMA": true, "DATABASE": true, "SEQUENCE": true,
+	"TRIGGER": true, "FUNCTION": true, "PROCEDURE": true, "TYPE": true,
+}
+
+// extractObjectName returns the name of the object a CREATE, ALTER, DROP
+// or TRUNCATE statement acts on, e.g. "users" in
+// "DROP TABLE IF EXISTS users"
+func extractObjectName(query string) string {
+	query = skipSpaceAndComments(query)
+	rest := query[len(leadingWord(query)):]
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		word := leadingWord(rest)
+		if word == "" || !ddlModifiers[upperASCII(word)] {
+			break
+		}
+		rest = rest[len(word):]
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		if c == ' ' || c == '(' || c == ';' {
+			break
+		}
+		if c == '`' || c == '"' || c == '\'' {
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// extractUpdateColumns returns the assignment targets of an UPDATE SET clause.
+func extractUpdateColumns(query string) []string {
+	var columns []string
+	for _, assignment := range extractUpdateAssignments(query) {
+		columns = append(columns, assignment[0])
+	}
+	return columns
+}
+
+// extractUpdateAssignments returns the column and value expression of each
+// assignment in an UPDATE SET clause.
+func extractUpdateAssignments(query string) [][2]string {
+	setPos := indexKeyword(query, "SET")
+	if setPos < 0 {
+		return nil
+	}
+
+	clause := query[setPos+len("SET"):]
+	for _, keyword := range []string{"WHERE", "RETURNING"} {
+		if end := indexKeyword(clause, keyword); end >= 0 {
+			clause = clause[:end]
+		}
+	}
+
+	var assignments [][2]string
+	for _, assignment := range splitTopLevel(clause, ',') {
+		eq := strings.IndexByte(assignment, '=')
+		if eq < 0 {
+			continue
+		}
+		if column := unquoteIdentifier(strings.TrimSpace(assignment[:eq])); column != "" {
+			value := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(assignment[eq+1:]), ";"))
+			assignments = append(assignments, [2]string{column, value})
+		}
+	}
+
+	return assignments
+}
+
+// extractInsertColumns returns the explicit column list of an INSERT, or "*"
+// when the statement does not name its columns.
+func extractInsertColumns(query string) []string {
+	open := strings.IndexByte(query, '(')
+	if values := indexKeyword(query, "VALUES"); values >= 0 && (open < 0 || open > values) {
+		return []string{"*"}
+	}
+	if open < 0 {
+		return []string{"*"}
+	}
+
+	close := strings.IndexByte(query[open:], ')')
+	if close < 0 {
+		return []string{"*"}
+	}
+
+	var columns []string
+	for _, column := range splitTopLevel(query[open+1:open+close], ',') {
+		if column = unquoteIdentifier(strings.TrimSpace(column)); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	if len(columns) == 0 {
+		return []string{"*"}
+	}
+
+	return columns
+}
+
+// insertSelectSource returns the SELECT feeding an INSERT ... SELECT, or ""
+// for any other INSERT. Such an insert is reported as a single table-level
+// change; its WHERE clause filters the source rows, so it is not recorded.
+func insertSelectSource(query string) string {
+	insert := indexKeyword(query, "INSERT INTO")
+	if insert < 0 {
+		return ""
+	}
+	rest := query[insert:]
+
+	sel := indexKeyword(rest, "SELECT")
+	if sel < 0 || indexKeyword(rest[:sel], "VALUES") >= 0 {
+		return ""
+	}
+	return rest[sel:]
+}
+
+// extractSourceTables returns the tables a SELECT reads from its FROM list
+// and JOIN clauses, in order of appearance and without duplicates.
+// Subqueries are skipped.
+func extractSourceTables(query string) []string {
+	var tables []string
+	add := func(item string) {
+		item = strings.TrimSpace(item)
+		if item == "" || item[0] == '(' {
+			return
+		}
+		if end := strings.IndexAny(item, " ;)"); end >= 0 {
+			item = item[:end]
+		}
+		item = unquoteIdentifier(item)
+		for _, table := range tables {
+			if table == item {
+				return
+			}
+		}
+		tables = append(tables, item)
+	}
+
+	if from := indexKeyword(query, "FROM"); from >= 0 {
+		list := query[from+len("FROM"):]
+		for _, keyword := range []string{"WHERE", "GROUP BY", "ORDER BY", "LIMIT", "UNION", "JOIN",
+			"INNER", "LEFT", "RIGHT", "FULL", "CROSS", "NATURAL"} {
+			if end := indexKeyword(list, keyword); end >= 0 {
+				list = list[:end]
+			}
+		}
+		for _, item := range splitTopLevel(list, ',') {
+			add(item)
+		}
+	}
+
+	for _, part := range splitKeyword(query, "JOIN")[1:] {
+		add(part)
+	}
+
+	return tables
+}
+
+// insertChanges emits one change per (row, column) pair of a VALUES list.
+// NewValue is the literal from the tuple unless the caller supplied one, and
+// rowsAffected defaults to the number of tuples. InferredType always
+// describes the tuple's literal.
+func insertChanges(tuples [][]string, columns []string, table string, rowsAffected int, database, oldValue, newValue, query string) []SQLChange {
+	if rowsAffected == 0 {
+		rowsAffected = len(tuples)
+	}
+
+	changes := make([]SQLChange, 0, len(tuples)*len(columns))
+	for row, values := range tuples {
+		for i, column := range columns {
+			var literal string
+			if len(values) == len(columns) {
+				literal = values[i]
+			}
+			value := newValue
+			if value == "" {
+				value = unquoteLiteral(literal)
+			}
+			changes = append(changes, SQLChange{
+				TableName:    table,
+				ColumnName:   column,
+				Operation:    OpInsert,
+				OldValue:     oldValue,
+				NewValue:     value,
+				RowsAffected: rowsAffected,
+				Database:     database,
+				FullQuery:    query,
+				RowIndex:     row,
+				InferredType: inferType(literal),
+			})
+		}
+	}
+
+	return changes
+}
+
+// extractInsertTuples returns the literal values of each parenthesized tuple
+// following VALUES, as written in the query.
+func extractInsertTuples(query string) [][]string {
+	pos := indexKeyword(query, "VALUES")
+	if pos < 0 {
+		return nil
+	}
+
+	var tuples [][]string
+	rest := query[pos+len("VALUES"):]
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		if !strings.HasPrefix(rest, "(") {
+			return tuples
+		}
+
+		end := closingParen(rest)
+		if end < 0 {
+			return tuples
+		}
+
+		var values []string
+		for _, value := range splitTopLevel(rest[1:end], ',') {
+			values = append(values, strings.TrimSpace(value))
+		}
+		tuples = append(tuples, values)
+		rest = rest[end+1:]
+	}
+}
+
+// closingParen returns the index of the parenthesis closing the one at s[0],
+// skipping string literals and nested parentheses, or -1 if it is unclosed.
+func closingParen(s string) int {
+	var quote byte
+	depth := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote && s[i-1] != '\\' {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// unquoteLiteral strips the quotes from a single-quoted SQL string literal
+// and collapses doubled quotes inside it.
+func unquoteLiteral(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// literalValue returns the value of expr when it is a literal inferType
+// recognizes, unquoted if it is a string, and false for any other
+// expression, such as "count + 1" or "NOW()".
+func literalValue(expr string) (string, bool) {
+	if inferType(expr) == "" {
+		return "", false
+	}
+	return unquoteLiteral(expr), true
+}
+
+// inferType guesses the type of a SQL literal from its form: "string" for
+// a quoted string, "int" or "float" for a number, "null" for NULL and
+// "bool" for TRUE or FALSE. Anything else, such as an expression or a
+// placeholder, gives "".
+func inferType(literal string) string {
+	if len(literal) >= 2 && literal[0] == '\'' && literal[len(literal)-1] == '\'' {
+		return "string"
+	}
+
+	switch upperASCII(literal) {
+	case "NULL":
+		return "null"
+	case "TRUE", "FALSE":
+		return "bool"
+	}
+
+	// ParseFloat also takes forms such as "Inf" and hex floats, which are
+	// not SQL numbers
+	if literal == "" || strings.Trim(literal, "0123456789.+-eE") != "" {
+		return ""
+	}
+	if _, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(literal, 64); err == nil {
+		return "float"
+	}
+	return ""
+}
+
+// extractSelectColumns returns the projection list of a SELECT.
+func extractSelectColumns(query string) []string {
+	selectPos := indexKeyword(query, "SELECT")
+	if selectPos < 0 {
+		return []string{"*"}
+	}
+
+	projection := query[selectPos+len("SELECT"):]
+	if from := indexKeyword(projection, "FROM"); from >= 0 {
+		projection = projection[:from]
+	}
+
+	var columns []string
+	for _, column := range splitTopLevel(projection, ',') {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	if len(columns) == 0 {
+		return []string{"*"}
+	}
+
+	return columns
+}
+
+// whereClause returns the text of the WHERE clause, without the keyword and
+// without any trailing ORDER BY, GROUP BY, LIMIT or RETURNING clause.
+func whereClause(query string) string {
+	pos := indexKeyword(query, "WHERE")
+	if pos < 0 {
+		return ""
+	}
+
+	clause := query[pos+len("WHERE"):]
+	for _, keyword := range []string{"ORDER BY", "GROUP BY", "LIMIT", "RETURNING"} {
+		if end := indexKeyword(clause, keyword); end >= 0 {
+			clause = clause[:end]
+		}
+	}
+
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(clause), ";"))
+}
+
+// parseWhere extracts column = value predicates from an AND-joined WHERE
+// clause. Anything more complex yields nil rather than a partial result.
+func parseWhere(clause string) map[string]string {
+	if clause == "" || indexKeyword(clause, "OR") >= 0 {
+		return nil
+	}
+
+	where := make(map[string]string)
+	for _, predicate := range splitKeyword(clause, "AND") {
+		column, value, ok := parseEquality(strings.TrimSpace(predicate))
+		if !ok {
+			return nil
+		}
+		where[column] = value
+	}
+
+	return where
+}
+
+// parseEquality splits a single "column = literal" predicate. A quoted
+// literal is returned unquoted, with doubled quotes collapsed.
+func parseEquality(predicate string) (string, string, bool) {
+	eq := strings.IndexByte(predicate, '=')
+	if eq <= 0 || strings.ContainsAny(predicate[:eq], "<>!()") {
+		return "", "", false
+	}
+
+	column := unquoteIdentifier(strings.TrimSpace(predicate[:eq]))
+	value := strings.TrimSpace(predicate[eq+1:])
+	if column == "" || value == "" || strings.ContainsAny(column, " ") {
+		return "", "", false
+	}
+
+	if value[0] == '\'' {
+		if len(value) < 2 || value[len(value)-1] != '\'' {
+			return "", "", false
+		}
+		return column, unquoteLiteral(value), true
+	}
+
+	if strings.ContainsAny(value, " =<>!()") {
+		return "", "", false
+	}
+
+	return column, value, true
+}
+
+// splitKeyword splits s around each occurrence of keyword as located by
+// indexKeyword.
+func splitKeyword(s, keyword string) []string {
+	var parts []string
+	for {
+		pos := indexKeyword(s, keyword)
+		if pos < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:pos])
+		s = s[pos+len(keyword):]
+	}
+}
+
+// indexKeyword finds keyword in query case-insensitively, outside of string
+// literals and on word boundaries. It returns -1 when the keyword is absent.
+func indexKeyword(query, keyword string) int {
+	upper := upperASCII(query)
+	keyword = upperASCII(keyword)
+
+	var quote byte
+	for i := 0; i+len(keyword) <= len(upper); i++ {
+		c := upper[i]
+		if quote != 0 {
+			if c == quote && upper[i-1] != '\\' {
+				quote = 0
+			}
+			continue
+		}
+		if c == '\'' || c == '"' || c == '`' {
+			quote = c
+			continue
+		}
+		if upper[i:i+len(keyword)] != keyword {
+			continue
+		}
+		if i > 0 && isIdentByte(upper[i-1]) {
+			continue
+		}
+		if end := i + len(keyword); end < len(upper) && isIdentByte(upper[end]) {
+			continue
+		}
+		return i
+	}
+
+	return -1
+}
+
+// splitTopLevel splits s on sep, ignoring separators inside string literals
+// or parentheses.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote && s[i-1] != '\\' {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unquoteIdentifier strips one level of matching identifier quotes.
+func unquoteIdentifier(s string) string {
+	if len(s) >= 2 {
+		first, last := s[0], s[len(s)-1]
+		if (first == '`' || first == '"' || first == '\'') && first == last {
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// upperASCII upper-cases ASCII letters only, so byte offsets into the result
+// remain valid for the original string.
+func upperASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' {
+			b[i] = c - ('a' - 'A')
+		}
+	}
+	return string(b)
+}
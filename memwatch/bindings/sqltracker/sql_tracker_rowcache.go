@@ -0,0 +1,220 @@
+package sqltracker
+
+import (
+	"sort"
+	"strings"
+)
+
+// SetRowCache enables best-effort inference of UPDATE old values. With it
+// on, the tracker remembers column values it sees for rows identified by
+// simple equality predicates: the value passed with a single-column SELECT
+// such as "SELECT email FROM users WHERE id = 1", the values of each
+// inserted row, and the values UPDATEs assign. An UPDATE tracked without
+// an old value then gets the remembered one for each column it sets.
+// Rows are only matched by the exact same predicate, and the cache is kept
+// in memory and never evicted. Only columns SetTrackedColumns keeps are
+// cached, and redacted columns never are, as values or in row keys.
+// Disabling it drops the cache.
+func (t *SQLTracker) SetRowCache(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rowCache = nil
+	if enabled {
+		t.rowCache = make(map[string]map[string]string)
+	}
+}
+
+// applyRowCache fills in the OldValue of UPDATE changes from the row cache
+// and records the values changes reveal. changes come from a single query.
+func (t *SQLTracker) applyRowCache(changes []SQLChange) {
+	if len(changes) == 0 || changes[0].ParseError {
+		return
+	}
+
+	// Most trackers never enable the cache; don't serialise them on it
+	t.mu.RLock()
+	enabled := t.rowCache != nil
+	t.mu.RUnlock()
+	if !enabled {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rowCache == nil {
+		return
+	}
+
+	first := changes[0]
+	if t.redactsAny(first.Where) {
+		return
+	}
+	switch first.Operation {
+	case OpSelect:
+		if len(changes) == 1 && first.Where != nil && first.ColumnName != "*" && first.NewValue != "" && t.cacheable(first) {
+			t.cacheValue(rowKey(first.TableName, first.Where), first.ColumnName, first.NewValue)
+		}
+
+	case OpInsert:
+		// Each inserted column value identifies its row for the others
+		rows := make(map[int]map[string]string)
+		for _, change := range changes {
+			if change.ColumnName == "*" {
+				return
+			}
+			if !t.cacheable(change) {
+				continue
+			}
+			if rows[change.RowIndex] == nil {
+				rows[change.RowIndex] = make(map[string]string)
+			}
+			rows[change.RowIndex][change.ColumnName] = change.NewValue
+		}
+		for _, row := range rows {
+			for column, value := range row {
+				key := rowKey(first.TableName, map[string]string{column: value})
+				for other, otherValue := range row {
+					if other != column {
+						t.cacheValue(key, other, otherValue)
+					}
+				}
+			}
+		}
+
+	case OpUpdate:
+		if first.Where == nil {
+			return
+		}
+		t.fillOldValues(changes)
+		key := rowKey(first.TableName, first.Where)
+		assigned := assignedLiterals(first.FullQuery)
+		for i := range changes {
+			change := &changes[i]
+			if !t.cacheable(*change) {
+				continue
+			}
+
+			value, ok := change.NewValue, change.NewValue != ""
+			if !ok {
+				value, ok = assigned[change.ColumnName]
+			}
+			if ok {
+				t.cacheValue(key, change.ColumnName, value)
+			} else {
+				delete(t.rowCache[key], change.ColumnName)
+			}
+		}
+
+	case OpDelete:
+		if first.Where != nil {
+			delete(t.rowCache, rowKey(first.TableName, first.Where))
+		}
+	}
+}
+
+// fillOldValues sets the OldValue of UPDATE changes tracked without one
+// to the value the row cache remembers for their row. changes come from a
+// single query. t.mu must be held, at least for reading.
+func (t *SQLTracker) fillOldValues(changes []SQLChange) {
+	if t.rowCache == nil || len(changes) == 0 {
+		return
+	}
+	first := changes[0]
+	if first.Operation != OpUpdate || first.Where == nil || t.redactsAny(first.Where) {
+		return
+	}
+
+	key := rowKey(first.TableName, first.Where)
+	for i := range changes {
+		change := &changes[i]
+		if change.OldValue != "" || !t.cacheable(*change) {
+			continue
+		}
+		if old, ok := t.rowCache[key][change.ColumnName]; ok {
+			change.OldValue = old
+			change.NoOp = len(changes) == 1 && change.NewValue != "" && old == change.NewValue
+		}
+	}
+}
+
+// cacheable reports whether the row cache may hold change's value: the
+// tracker keeps the change and does not redact its column. t.mu must be
+// held.
+func (t *SQLTracker) cacheable(change SQLChange) bool {
+	return t.keeps(change) && !t.redacted[strings.ToLower(change.ColumnName)]
+}
+
+// redactsAny reports whether any column of where is redacted, making its
+// value unfit for a row key. t.mu must be held.
+func (t *SQLTracker) redactsAny(where map[string]string) bool {
+	for column := range where {
+		if t.redacted[strings.ToLower(column)] {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheValue remembers value for column of the row identified by key
+func (t *SQLTracker) cacheValue(key, column, value string) {
+	row := t.rowCache[key]
+	if row == nil {
+		row = make(map[string]string)
+		t.rowCache[key] = row
+	}
+	row[column] = value
+}
+
+// GroupByRow groups the tracked changes by the row they touched, keeping
+// tracking order within each group, e.g. as the input of a change feed.
+// Rows are identified by the equality predicates of the Where map, keyed
+// "users|id=1" with the columns sorted; changes without one, including
+// inserts, are grouped per table under "users|*".
+func (t *SQLTracker) GroupByRow() map[string][]SQLChange {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	groups := make(map[string][]SQLChange)
+	for _, change := range t.changes {
+		key := change.TableName + "|*"
+		if len(change.Where) > 0 {
+			key = rowKey(change.TableName, change.Where)
+		}
+		groups[key] = append(groups[key], change)
+	}
+	return groups
+}
+
+// rowKey identifies the rows of table matching the equalities in where,
+// independent of the order they were written in
+func rowKey(table string, where map[string]string) string {
+	columns := make([]string, 0, len(where))
+	for column := range where {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var b strings.Builder
+	b.WriteString(table)
+	for _, column := range columns {
+		b.WriteString("|")
+		b.WriteString(column)
+		b.WriteString("=")
+		b.WriteString(where[column])
+	}
+	return b.String()
+}
+
+// assignedLiterals returns the literals an UPDATE's SET clause assigns,
+// keyed by column. Computed values are left out.
+func assignedLiterals(query string) map[string]string {
+	values := make(map[string]string)
+	for _, assignment := range extractUpdateAssignments(mainStatement(query)) {
+		if value, ok := literalValue(assignment[1]); ok {
+			values[assignment[0]] = value
+		}
+	}
+	return values
+}
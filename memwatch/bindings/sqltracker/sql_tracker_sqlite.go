@@ -0,0 +1,242 @@
+package sqltracker
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema creates the changes table as first released, schema
+// version 1, and its lookup indexes. sqliteMigrations brings it up to date.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS changes (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp_ns  INTEGER NOT NULL,
+	table_name    TEXT NOT NULL,
+	column_name   TEXT NOT NULL,
+	operation     INTEGER NOT NULL,
+	old_value     TEXT NOT NULL,
+	new_value     TEXT NOT NULL,
+	rows_affected INTEGER NOT NULL,
+	database      TEXT NOT NULL,
+	full_query    TEXT NOT NULL,
+	where_json    TEXT
+);
+CREATE INDEX IF NOT EXISTS changes_table_name ON changes (table_name);
+CREATE INDEX IF NOT EXISTS changes_column_name ON changes (column_name);
+CREATE INDEX IF NOT EXISTS changes_timestamp_ns ON changes (timestamp_ns);
+`
+
+// sqliteMigrations holds the columns each later schema version added;
+// entry i takes a database from version i+1 to i+2. Append new columns as
+// a new entry, never by editing an old one.
+var sqliteMigrations = [][]string{
+	{"parse_error INTEGER NOT NULL DEFAULT 0"},
+	{"row_index INTEGER NOT NULL DEFAULT 0"},
+	{"fingerprint TEXT NOT NULL DEFAULT ''"},
+	{"no_op INTEGER NOT NULL DEFAULT 0"},
+	{"query_truncated INTEGER NOT NULL DEFAULT 0"},
+	{"source_tables_json TEXT"},
+	{"predicate TEXT NOT NULL DEFAULT ''", "full_table_delete INTEGER NOT NULL DEFAULT 0"},
+	{"inferred_type TEXT NOT NULL DEFAULT ''"},
+}
+
+const sqliteInsert = `INSERT INTO changes
+	(timestamp_ns, table_name, column_name, operation, old_value, new_value, rows_affected, database, full_query, where_json, parse_error, row_index, fingerprint, no_op, query_truncated, source_tables_json, predicate, full_table_delete, inferred_type)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const sqliteSelect = `SELECT
+	timestamp_ns, table_name, column_name, operation, old_value, new_value, rows_affected, database, full_query, where_json, parse_error, row_index, fingerprint, no_op, query_truncated, source_tables_json, predicate, full_table_delete, inferred_type
+	FROM changes ORDER BY id`
+
+// SQLiteStorage stores each change as a row of a "changes" table
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteStorage opens the SQLite database at dsn, creating the schema if
+// it does not exist yet. ":memory:" gives a private in-memory database.
+func NewSQLiteStorage(dsn string) (*SQLiteStorage, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection keeps ":memory:" databases alive and shared
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+// migrateSQLite adds the columns the database's user_version predates and
+// records the latest version. Databases written before versioning report
+// version 0 and may hold any subset of the columns, so only the missing
+// ones are added.
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	latest := len(sqliteMigrations) + 1
+	if version >= latest {
+		return nil
+	}
+
+	existing, err := sqliteColumns(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for v := version; v < latest; v++ {
+		if v == 0 {
+			continue
+		}
+		for _, column := range sqliteMigrations[v-1] {
+			if existing[strings.Fields(column)[0]] {
+				continue
+			}
+			if _, err := tx.Exec("ALTER TABLE changes ADD COLUMN " + column); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migrate changes table to version %d: %w", v+1, err)
+			}
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", latest)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteColumns returns the names of the changes table's columns
+func sqliteColumns(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("PRAGMA table_info(changes)")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// DB returns the underlying database for ad-hoc queries
+func (s *SQLiteStorage) DB() *sql.DB {
+	return s.db
+}
+
+// Append inserts change as a new row
+func (s *SQLiteStorage) Append(change SQLChange) error {
+	return s.AppendBatch([]SQLChange{change})
+}
+
+// AppendBatch inserts all changes in a single transaction
+func (s *SQLiteStorage) AppendBatch(changes []SQLChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(sqliteInsert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, change := range changes {
+		var where interface{}
+		if change.Where != nil {
+			data, err := json.Marshal(change.Where)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			where = string(data)
+		}
+		var sources interface{}
+		if change.SourceTables != nil {
+			data, err := json.Marshal(change.SourceTables)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			sources = string(data)
+		}
+
+		_, err := stmt.Exec(change.TimestampNs, change.TableName, change.ColumnName, change.Operation,
+			change.OldValue, change.NewValue, change.RowsAffected, change.Database, change.FullQuery, where, change.ParseError, change.RowIndex, change.Fingerprint, change.NoOp, change.QueryTruncated, sources, change.Predicate, change.FullTableDelete, change.InferredType)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadAll reads every row back in insertion order
+func (s *SQLiteStorage) LoadAll() ([]SQLChange, error) {
+	rows, err := s.db.Query(sqliteSelect)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []SQLChange
+	for rows.Next() {
+		var change SQLChange
+		var where, sources sql.NullString
+		err := rows.Scan(&change.TimestampNs, &change.TableName, &change.ColumnName, &change.Operation,
+			&change.OldValue, &change.NewValue, &change.RowsAffected, &change.Database, &change.FullQuery, &where, &change.ParseError, &change.RowIndex, &change.Fingerprint, &change.NoOp, &change.QueryTruncated, &sources, &change.Predicate, &change.FullTableDelete, &change.InferredType)
+		if err != nil {
+			return changes, err
+		}
+		if where.Valid {
+			if err := json.Unmarshal([]byte(where.String), &change.Where); err != nil {
+				return changes, err
+			}
+		}
+		if sources.Valid {
+			if err := json.Unmarshal([]byte(sources.String), &change.SourceTables); err != nil {
+				return changes, err
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// Close closes the database
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,198 @@
+package sqltracker
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// Storage persists tracked changes. Implementations need not be safe for
+// concurrent use; SQLTracker serializes all calls under its own lock.
+type Storage interface {
+	// Append persists a single change
+	Append(change SQLChange) error
+	// LoadAll returns every persisted change in the order it was appended
+	LoadAll() ([]SQLChange, error)
+	// Close releases any resources held by the storage
+	Close() error
+}
+
+// batchAppender is implemented by storages that can persist several changes
+// more cheaply than one Append call per change
+type batchAppender interface {
+	AppendBatch(changes []SQLChange) error
+}
+
+// JSONLStorage stores changes as one JSON object per line in a file
+type JSONLStorage struct {
+	path string
+	file *os.File
+}
+
+// NewJSONLStorage returns a storage appending to the file at path. The file
+// is created on the first append.
+func NewJSONLStorage(path string) *JSONLStorage {
+	return &JSONLStorage{path: path}
+}
+
+// Append writes change as a single JSON line
+func (s *JSONLStorage) Append(change SQLChange) error {
+	return s.AppendBatch([]SQLChange{change})
+}
+
+// AppendBatch writes all changes with a single write
+func (s *JSONLStorage) AppendBatch(changes []SQLChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if s.file == nil {
+		f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		s.file = f
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, change := range changes {
+		if err := enc.Encode(change); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.file.Write(buf.Bytes())
+	return err
+}
+
+// LoadAll reads every change from the file. A missing file holds no changes.
+func (s *JSONLStorage) LoadAll() ([]SQLChange, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var changes []SQLChange
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var change SQLChange
+		if err := dec.Decode(&change); err != nil {
+			return changes, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Close closes the underlying file, if it was opened
+func (s *JSONLStorage) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// GzipJSONLStorage stores changes as gzip-compressed JSON lines. Each
+// append is flushed to the file, so a crash loses at most the change being
+// written; reopening an existing file adds a new gzip member.
+type GzipJSONLStorage struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+}
+
+// NewGzipJSONLStorage returns a storage appending to the gzip file at path.
+// The file is created on the first append.
+func NewGzipJSONLStorage(path string) *GzipJSONLStorage {
+	return &GzipJSONLStorage{path: path}
+}
+
+// Append compresses change as a single JSON line
+func (s *GzipJSONLStorage) Append(change SQLChange) error {
+	return s.AppendBatch([]SQLChange{change})
+}
+
+// AppendBatch compresses all changes and flushes them to the file
+func (s *GzipJSONLStorage) AppendBatch(changes []SQLChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if s.gz == nil {
+		f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		s.file = f
+		s.gz = gzip.NewWriter(f)
+	}
+
+	enc := json.NewEncoder(s.gz)
+	for _, change := range changes {
+		if err := enc.Encode(change); err != nil {
+			return err
+		}
+	}
+
+	return s.gz.Flush()
+}
+
+// LoadAll decompresses every change from the file. A missing file holds no
+// changes. Changes decoded before a truncated tail are still returned.
+func (s *GzipJSONLStorage) LoadAll() ([]SQLChange, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var changes []SQLChange
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var change SQLChange
+		if err := dec.Decode(&change); err != nil {
+			return changes, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Close finishes the gzip stream and closes the file
+func (s *GzipJSONLStorage) Close() error {
+	if s.gz == nil {
+		return nil
+	}
+	err := s.gz.Close()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.gz = nil
+	s.file = nil
+	return err
+}
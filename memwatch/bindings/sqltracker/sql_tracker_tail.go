@@ -0,0 +1,86 @@
+package sqltracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// tailPollInterval is how often TailChanges checks the file for new data
+var tailPollInterval = 100 * time.Millisecond
+
+// TailChanges follows the JSONL file at path, such as one written by
+// JSONLStorage in another process, and sends each change appended after
+// the call on the returned channel. Existing content is skipped. A line is
+// decoded once its newline arrives, so partially written lines are held
+// back; lines that are not valid changes are skipped. If the file shrinks,
+// it is assumed to have been truncated and is read again from the start.
+// The channel is closed when ctx is cancelled.
+func TailChanges(ctx context.Context, path string) (<-chan SQLChange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	out := make(chan SQLChange)
+	go func() {
+		defer close(out)
+		defer f.Close()
+
+		ticker := time.NewTicker(tailPollInterval)
+		defer ticker.Stop()
+
+		var pending []byte
+		buf := make([]byte, 32*1024)
+		for {
+			if info, err := f.Stat(); err == nil && info.Size() < offset {
+				offset, _ = f.Seek(0, io.SeekStart)
+				pending = pending[:0]
+			}
+
+			for {
+				n, err := f.Read(buf)
+				offset += int64(n)
+				pending = append(pending, buf[:n]...)
+				if err != nil || n == 0 {
+					break
+				}
+			}
+
+			for {
+				end := bytes.IndexByte(pending, '\n')
+				if end < 0 {
+					break
+				}
+				line := pending[:end]
+				pending = pending[end+1:]
+
+				var change SQLChange
+				if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &change) != nil {
+					continue
+				}
+				select {
+				case out <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,1687 @@
+package sqltracker
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetDedupSuppressesRepeatedUpdate(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+	tracker.SetDedup(true)
+
+	query := "UPDATE users SET email = 'new@example.com' WHERE id = 1"
+
+	if n := tracker.TrackQuery(query, 1, "mydb", "old@example.com", "new@example.com"); n != 1 {
+		t.Fatalf("first TrackQuery = %d, want 1", n)
+	}
+	if n := tracker.TrackQuery(query, 1, "mydb", "old@example.com", "new@example.com"); n != 0 {
+		t.Fatalf("duplicate TrackQuery = %d, want 0", n)
+	}
+
+	changes := tracker.GetChanges("", "", "")
+	if len(changes) != 1 {
+		t.Fatalf("retained %d changes, want 1", len(changes))
+	}
+	if changes[0].TableName != "users" || changes[0].ColumnName != "email" {
+		t.Errorf("unexpected change %s.%s", changes[0].TableName, changes[0].ColumnName)
+	}
+}
+
+func TestOnChangeCallbacksFireInOrder(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	var order []string
+	var first, second []SQLChange
+	tracker.OnChange(func(c SQLChange) {
+		order = append(order, "first")
+		first = append(first, c)
+	})
+	tracker.OnChange(func(c SQLChange) {
+		order = append(order, "second")
+		second = append(second, c)
+	})
+
+	n := tracker.TrackQuery("INSERT INTO users (name, email, age) VALUES ('Alice', 'alice@example.com', 30)", 1, "mydb", "", "")
+	if n != 3 {
+		t.Fatalf("TrackQuery = %d, want 3", n)
+	}
+
+	want := []string{"name", "email", "age"}
+	for _, got := range [][]SQLChange{first, second} {
+		if len(got) != len(want) {
+			t.Fatalf("callback saw %d changes, want %d", len(got), len(want))
+		}
+		for i, c := range got {
+			if c.ColumnName != want[i] {
+				t.Errorf("change %d column = %q, want %q", i, c.ColumnName, want[i])
+			}
+		}
+	}
+
+	for i := 0; i < len(order); i += 2 {
+		if order[i] != "first" || order[i+1] != "second" {
+			t.Fatalf("callbacks fired out of order: %v", order)
+		}
+	}
+}
+
+func TestExportCSVRoundTrip(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQuery("UPDATE users SET email = 'new@example.com' WHERE id = 1", 1, "mydb", "old@example.com", "new@example.com")
+	tracker.TrackQuery("DELETE FROM sessions WHERE id = 7", 2, "mydb", "", "")
+
+	var buf bytes.Buffer
+	cols := []string{"operation", "table", "column", "old_value", "new_value", "timestamp"}
+	if err := tracker.ExportCSV(&buf, cols); err != nil {
+		t.Fatalf("ExportCSV: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want header + 2 rows", len(records))
+	}
+
+	for i, col := range cols {
+		if records[0][i] != col {
+			t.Errorf("header[%d] = %q, want %q", i, records[0][i], col)
+		}
+	}
+
+	changes := tracker.GetChanges("", "", "")
+	want := [][]string{
+		{"UPDATE", "users", "email", "old@example.com", "new@example.com", strconv.FormatInt(changes[0].TimestampNs, 10)},
+		{"DELETE", "sessions", "*", "", "", strconv.FormatInt(changes[1].TimestampNs, 10)},
+	}
+	for r, row := range want {
+		for i, field := range row {
+			if records[r+1][i] != field {
+				t.Errorf("row %d field %q = %q, want %q", r, cols[i], records[r+1][i], field)
+			}
+		}
+	}
+}
+
+func TestExportCSVDefaultsToAllColumns(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	var buf bytes.Buffer
+	if err := tracker.ExportCSV(&buf, nil); err != nil {
+		t.Fatalf("ExportCSV: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(records) != 1 || len(records[0]) != len(csvColumns) {
+		t.Fatalf("header = %v, want %v", records, csvColumns)
+	}
+
+	if err := tracker.ExportCSV(&buf, []string{"bogus"}); err == nil {
+		t.Error("expected error for unknown column")
+	}
+}
+
+func TestTrackQueryParsesWhereEqualities(t *testing.T) {
+	tests := []struct {
+		query string
+		want  map[string]string
+	}{
+		{"DELETE FROM users WHERE id = 1", map[string]string{"id": "1"}},
+		{"UPDATE users SET name = 'y' WHERE a = 1 AND b = 'x'", map[string]string{"a": "1", "b": "x"}},
+		{"DELETE FROM users WHERE name = 'O''Brien' AND id = 2", map[string]string{"name": "O'Brien", "id": "2"}},
+		{"DELETE FROM users WHERE (a = 1 OR b > 2) AND c IN (1, 2)", nil},
+	}
+
+	for _, tt := range tests {
+		tracker := New("")
+		if n := tracker.TrackQuery(tt.query, 1, "mydb", "", ""); n != 1 {
+			t.Fatalf("%q: TrackQuery = %d, want 1", tt.query, n)
+		}
+
+		got := tracker.GetChanges("", "", "")[0].Where
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: Where = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%q: Where[%q] = %q, want %q", tt.query, k, got[k], v)
+			}
+		}
+		tracker.Close()
+	}
+}
+
+func TestPrometheusCollectorCounts(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQuery("INSERT INTO users (name, email) VALUES ('Alice', 'alice@example.com')", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'new@example.com' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("DELETE FROM sessions WHERE id = 7", 1, "mydb", "", "")
+
+	expected := `
+# HELP sqltracker_changes_total Total number of tracked column changes.
+# TYPE sqltracker_changes_total counter
+sqltracker_changes_total 4
+# HELP sqltracker_operation_changes_total Number of tracked column changes per SQL operation.
+# TYPE sqltracker_operation_changes_total counter
+sqltracker_operation_changes_total{operation="alter"} 0
+sqltracker_operation_changes_total{operation="create"} 0
+sqltracker_operation_changes_total{operation="delete"} 1
+sqltracker_operation_changes_total{operation="drop"} 0
+sqltracker_operation_changes_total{operation="insert"} 2
+sqltracker_operation_changes_total{operation="select"} 0
+sqltracker_operation_changes_total{operation="truncate"} 0
+sqltracker_operation_changes_total{operation="update"} 1
+# HELP sqltracker_tables Number of distinct tables touched by tracked changes.
+# TYPE sqltracker_tables gauge
+sqltracker_tables 2
+`
+	if err := testutil.CollectAndCompare(tracker.PrometheusCollector(), strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryMentions(t *testing.T) {
+	tests := []struct {
+		query, term string
+		want        bool
+	}{
+		{"UPDATE users SET password = 'x'", "password", true},
+		{"UPDATE users SET PASSWORD = 'x'", "password", true},
+		{"update users set email = 'x'", "EMAIL", true},
+		{"SELECT ssn FROM employees", "credit_card", false},
+		{"ssn", "ssn_hash", false},
+		{"SELECT 1", "", false},
+		// The Kelvin sign is three bytes but lowers to a one-byte k
+		{"k", "\u212a", true},
+	}
+
+	for _, tt := range tests {
+		if got := QueryMentions(tt.query, tt.term); got != tt.want {
+			t.Errorf("QueryMentions(%q, %q) = %v, want %v", tt.query, tt.term, got, tt.want)
+		}
+	}
+
+	exact := []struct {
+		query, term string
+		want        bool
+	}{
+		{`SELECT "Email" FROM users`, "Email", true},
+		{`SELECT "Email" FROM users`, "email", false},
+		{"SELECT 1", "", false},
+	}
+	for _, tt := range exact {
+		if got := QueryMentionsExact(tt.query, tt.term); got != tt.want {
+			t.Errorf("QueryMentionsExact(%q, %q) = %v, want %v", tt.query, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestWebSocketHandlerFiltersByTable(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	srv := httptest.NewServer(tracker.WebSocketHandler())
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?table=users", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	tracker.TrackQuery("UPDATE orders SET status = 'paid' WHERE id = 3", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'new@example.com' WHERE id = 1", 1, "mydb", "", "")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var got SQLChange
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got.TableName != "users" || got.ColumnName != "email" {
+		t.Fatalf("received %s.%s, want users.email", got.TableName, got.ColumnName)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := conn.ReadJSON(&got); err == nil {
+		t.Errorf("unexpected extra change %s.%s", got.TableName, got.ColumnName)
+	}
+}
+
+func TestWebSocketHandlerRejectsForeignOrigin(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	srv := httptest.NewServer(tracker.WebSocketHandler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	foreign := http.Header{"Origin": {"https://evil.example"}}
+	conn, resp, err := websocket.DefaultDialer.Dial(url, foreign)
+	if err == nil {
+		conn.Close()
+		t.Fatal("dial with a foreign Origin succeeded, want it refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign Origin response = %v, want 403", resp)
+	}
+
+	same := http.Header{"Origin": {srv.URL}}
+	conn, _, err = websocket.DefaultDialer.Dial(url, same)
+	if err != nil {
+		t.Fatalf("dial with the server's own Origin: %v", err)
+	}
+	conn.Close()
+}
+
+var batchQueries = []QuerySpec{
+	{Query: "INSERT INTO users (name, email) VALUES ('Alice', 'alice@example.com')", RowsAffected: 1, Database: "mydb"},
+	{Query: "UPDATE users SET email = 'new@example.com' WHERE id = 1", RowsAffected: 1, Database: "mydb", OldValue: "alice@example.com", NewValue: "new@example.com"},
+	{Query: "not a query", Database: "mydb"},
+	{Query: "DELETE FROM users WHERE id = 1", RowsAffected: 1, Database: "mydb"},
+}
+
+func TestTrackBatchMatchesIndividualCalls(t *testing.T) {
+	individual := New("")
+	defer individual.Close()
+	want := 0
+	for _, q := range batchQueries {
+		want += individual.TrackQuery(q.Query, q.RowsAffected, q.Database, q.OldValue, q.NewValue)
+	}
+
+	batched := New("")
+	defer batched.Close()
+	if got := batched.TrackBatch(batchQueries); got != want {
+		t.Fatalf("TrackBatch = %d, want %d", got, want)
+	}
+
+	a, b := individual.GetChanges("", "", ""), batched.GetChanges("", "", "")
+	if len(a) != len(b) {
+		t.Fatalf("individual tracked %d changes, batch %d", len(a), len(b))
+	}
+	for i := range a {
+		if !a[i].sameAs(b[i]) || a[i].FullQuery != b[i].FullQuery || a[i].RowsAffected != b[i].RowsAffected {
+			t.Errorf("change %d: individual %+v, batch %+v", i, a[i], b[i])
+		}
+	}
+}
+
+func BenchmarkTrackQueryIndividual(b *testing.B) {
+	tracker := New(filepath.Join(b.TempDir(), "changes.jsonl"))
+	defer tracker.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, q := range batchQueries {
+			tracker.TrackQuery(q.Query, q.RowsAffected, q.Database, q.OldValue, q.NewValue)
+		}
+	}
+}
+
+func BenchmarkTrackBatch(b *testing.B) {
+	tracker := New(filepath.Join(b.TempDir(), "changes.jsonl"))
+	defer tracker.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tracker.TrackBatch(batchQueries)
+	}
+}
+
+// Run with -race to check for unsynchronized access
+func TestConcurrentTrackAndRead(t *testing.T) {
+	tracker := New(filepath.Join(t.TempDir(), "changes.jsonl"))
+	defer tracker.Close()
+
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				tracker.TrackQuery("UPDATE users SET email = 'x' WHERE id = 1", 1, "mydb", "", "")
+			}
+		}()
+	}
+
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					tracker.GetChanges("users", "", "")
+					tracker.GetSummary()
+					Get().GetSummary()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	if got := tracker.GetSummary().TotalChanges; got != writers*perWriter {
+		t.Errorf("TotalChanges = %d, want %d", got, writers*perWriter)
+	}
+}
+
+// memoryStorage is an in-memory Storage recording every Append
+type memoryStorage struct {
+	changes []SQLChange
+	appends int
+	closed  bool
+}
+
+func (s *memoryStorage) Append(change SQLChange) error {
+	s.appends++
+	s.changes = append(s.changes, change)
+	return nil
+}
+
+func (s *memoryStorage) LoadAll() ([]SQLChange, error) {
+	return append([]SQLChange(nil), s.changes...), nil
+}
+
+func (s *memoryStorage) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestNewWithStorageAppendsAndReloads(t *testing.T) {
+	storage := &memoryStorage{}
+	tracker := NewWithStorage(storage)
+
+	tracker.TrackQuery("UPDATE users SET email = 'a', name = 'b' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("DELETE FROM orders WHERE id = 7", 1, "mydb", "", "")
+	if storage.appends != 3 {
+		t.Fatalf("Append called %d times, want 3", storage.appends)
+	}
+
+	tracker.Close()
+	if !storage.closed {
+		t.Error("Close did not close the storage")
+	}
+
+	reloaded := NewWithStorage(storage)
+	got := reloaded.GetChanges("", "", "")
+	if len(got) != 3 {
+		t.Fatalf("reloaded %d changes, want 3", len(got))
+	}
+	if got[0].ColumnName != "email" || got[1].ColumnName != "name" || got[2].TableName != "orders" {
+		t.Errorf("reloaded history out of order: %+v", got)
+	}
+}
+
+// failingStorage is a Storage whose writes all fail with err
+type failingStorage struct {
+	memoryStorage
+	err error
+}
+
+func (s *failingStorage) Append(change SQLChange) error {
+	s.appends++
+	return s.err
+}
+
+// failingBatchStorage is a failingStorage that also fails batch writes
+type failingBatchStorage struct {
+	failingStorage
+	batches int
+}
+
+func (s *failingBatchStorage) AppendBatch(changes []SQLChange) error {
+	s.batches++
+	return s.err
+}
+
+func TestPersistErrorsRecordsFailedWrites(t *testing.T) {
+	diskFull := errors.New("disk full")
+
+	storage := &failingStorage{err: diskFull}
+	tracker := NewWithStorage(storage)
+	defer tracker.Close()
+
+	if err := tracker.LastPersistError(); err != nil {
+		t.Fatalf("LastPersistError() before any write = %v, want nil", err)
+	}
+	tracker.TrackQuery("UPDATE users SET email = 'a', name = 'b' WHERE id = 1", 1, "mydb", "", "")
+	if storage.appends != 1 {
+		t.Errorf("Append called %d times, want 1 as writing stops at the first failure", storage.appends)
+	}
+	if got := tracker.PersistErrors(); got != 1 {
+		t.Errorf("PersistErrors() = %d, want 1", got)
+	}
+	if err := tracker.LastPersistError(); !errors.Is(err, diskFull) {
+		t.Errorf("LastPersistError() = %v, want %v", err, diskFull)
+	}
+	if got := len(tracker.GetChanges("", "", "")); got != 2 {
+		t.Errorf("GetChanges returned %d changes, want 2 kept in memory", got)
+	}
+
+	batch := &failingBatchStorage{failingStorage: failingStorage{err: diskFull}}
+	batched := NewWithStorage(batch)
+	defer batched.Close()
+
+	batched.TrackBatch([]QuerySpec{
+		{Query: "DELETE FROM orders WHERE id = 1", RowsAffected: 1},
+		{Query: "DELETE FROM orders WHERE id = 2", RowsAffected: 1},
+	})
+	if batch.batches != 1 || batched.PersistErrors() != 1 || !errors.Is(batched.LastPersistError(), diskFull) {
+		t.Errorf("batches = %d, PersistErrors() = %d, LastPersistError() = %v, want 1, 1, %v",
+			batch.batches, batched.PersistErrors(), batched.LastPersistError(), diskFull)
+	}
+}
+
+func TestJSONLStorageReloadsHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.jsonl")
+
+	tracker := New(path)
+	tracker.TrackQuery("UPDATE users SET email = 'a' WHERE id = 1", 1, "mydb", "", "")
+	tracker.Close()
+
+	reloaded := New(path)
+	defer reloaded.Close()
+
+	got := reloaded.GetChanges("users", "email", "UPDATE")
+	if len(got) != 1 || got[0].Where["id"] != "1" {
+		t.Errorf("reloaded = %+v, want one users.email update with id=1", got)
+	}
+}
+
+func TestSQLiteStorageRowsAndIndexes(t *testing.T) {
+	storage, err := NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	tracker := NewWithStorage(storage)
+	defer tracker.Close()
+
+	tracker.TrackQuery("INSERT INTO users (name, email) VALUES ('a', 'b')", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'c' WHERE id = 1", 1, "mydb", "b", "c")
+
+	var count int
+	err = storage.DB().QueryRow("SELECT COUNT(*) FROM changes WHERE table_name = 'users' AND column_name = 'email'").Scan(&count)
+	if err != nil {
+		t.Fatalf("query changes: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("users.email rows = %d, want 2", count)
+	}
+
+	rows, err := storage.DB().Query("PRAGMA index_list(changes)")
+	if err != nil {
+		t.Fatalf("PRAGMA index_list: %v", err)
+	}
+	indexes := make(map[string]bool)
+	for rows.Next() {
+		var seq, unique int
+		var name, origin string
+		var partial int
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			t.Fatalf("scan index_list: %v", err)
+		}
+		indexes[name] = true
+	}
+	rows.Close()
+	for _, name := range []string{"changes_table_name", "changes_column_name", "changes_timestamp_ns"} {
+		if !indexes[name] {
+			t.Errorf("index %s missing, have %v", name, indexes)
+		}
+	}
+
+	loaded, err := storage.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	want := []string{"name", "email", "email"}
+	if len(loaded) != len(want) {
+		t.Fatalf("LoadAll returned %d changes, want %d", len(loaded), len(want))
+	}
+	for i, column := range want {
+		if loaded[i].ColumnName != column {
+			t.Errorf("loaded[%d].ColumnName = %q, want %q", i, loaded[i].ColumnName, column)
+		}
+	}
+	if loaded[2].Where["id"] != "1" || loaded[2].NewValue != "c" {
+		t.Errorf("loaded[2] = %+v, want id=1 update to c", loaded[2])
+	}
+}
+
+func TestSQLiteStorageMigratesOlderDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	// An unversioned database from before versioning that already picked
+	// up one later column
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		t.Fatalf("create v1 schema: %v", err)
+	}
+	if _, err := db.Exec("ALTER TABLE changes ADD COLUMN parse_error INTEGER NOT NULL DEFAULT 0"); err != nil {
+		t.Fatalf("add parse_error: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO changes (timestamp_ns, table_name, column_name, operation, old_value, new_value, rows_affected, database, full_query)
+		VALUES (1, 'users', 'name', 1, '', 'old', 1, 'mydb', 'INSERT INTO users (name) VALUES (''old'')')`); err != nil {
+		t.Fatalf("insert v1 row: %v", err)
+	}
+	db.Close()
+
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	tracker := NewWithStorage(storage)
+	defer tracker.Close()
+
+	var version int
+	if err := storage.DB().QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatalf("PRAGMA user_version: %v", err)
+	}
+	if want := len(sqliteMigrations) + 1; version != want {
+		t.Errorf("user_version = %d, want %d", version, want)
+	}
+
+	tracker.TrackQuery("UPDATE users SET name = 'new' WHERE id = 1", 1, "mydb", "old", "new")
+	loaded, err := storage.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].NewValue != "old" || loaded[1].NewValue != "new" {
+		t.Fatalf("LoadAll = %+v, want the v1 row then the new update", loaded)
+	}
+
+	// Reopening an up-to-date database is a no-op
+	tracker.Close()
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	reopened.Close()
+}
+
+func TestSetClockStampsFixedInstant(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	instant := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	tracker.SetClock(func() time.Time { return instant })
+
+	tracker.TrackQuery("UPDATE users SET email = 'a' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET name = 'b' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("DELETE FROM users WHERE id = 1", 1, "mydb", "", "")
+
+	changes := tracker.GetChanges("", "", "")
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3", len(changes))
+	}
+	for i, change := range changes {
+		if change.TimestampNs != instant.UnixNano() {
+			t.Errorf("changes[%d].TimestampNs = %d, want %d", i, change.TimestampNs, instant.UnixNano())
+		}
+	}
+}
+
+func TestTrackQueryFlagsUnparseableQuery(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	if n := tracker.TrackQuery("  this is not sql  ", 0, "mydb", "", ""); n != 1 {
+		t.Fatalf("TrackQuery returned %d, want 1", n)
+	}
+
+	changes := tracker.GetChanges("", "", "UNKNOWN")
+	if len(changes) != 1 {
+		t.Fatalf("got %d unknown changes, want 1", len(changes))
+	}
+	if !changes[0].ParseError || changes[0].FullQuery != "this is not sql" {
+		t.Errorf("change = %+v, want ParseError with raw query", changes[0])
+	}
+	if got := tracker.ParseErrors(); got != 1 {
+		t.Errorf("ParseErrors() = %d, want 1", got)
+	}
+}
+
+func TestTrackQueryMultiRowInsert(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	query := "INSERT INTO users (name, bio) VALUES ('Alice', 'likes (a, b)'), ('Bob', 'it''s, fine'), ('Carol', '')"
+	if n := tracker.TrackQuery(query, 0, "mydb", "", ""); n != 6 {
+		t.Fatalf("TrackQuery returned %d, want 6", n)
+	}
+
+	want := []struct {
+		row    int
+		column string
+		value  string
+	}{
+		{0, "name", "Alice"}, {0, "bio", "likes (a, b)"},
+		{1, "name", "Bob"}, {1, "bio", "it's, fine"},
+		{2, "name", "Carol"}, {2, "bio", ""},
+	}
+	changes := tracker.GetChanges("users", "", "INSERT")
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(want))
+	}
+	for i, w := range want {
+		c := changes[i]
+		if c.RowIndex != w.row || c.ColumnName != w.column || c.NewValue != w.value || c.RowsAffected != 3 {
+			t.Errorf("changes[%d] = row %d %s=%q rows %d, want row %d %s=%q rows 3",
+				i, c.RowIndex, c.ColumnName, c.NewValue, c.RowsAffected, w.row, w.column, w.value)
+		}
+	}
+}
+
+func TestTrackQueryInsertSelect(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tests := []struct {
+		query   string
+		sources []string
+	}{
+		{"INSERT INTO archive SELECT * FROM users WHERE created < now()", []string{"users"}},
+		{"INSERT INTO archive (id, name) SELECT u.id, u.name FROM users u JOIN teams t ON t.id = u.team_id", []string{"users", "teams"}},
+		{"insert into archive select a.id from accounts a, `users` where a.id = users.id", []string{"accounts", "users"}},
+	}
+
+	for _, tt := range tests {
+		if n := tracker.TrackQuery(tt.query, 5, "mydb", "", ""); n != 1 {
+			t.Errorf("TrackQuery(%q) = %d changes, want one table-level change", tt.query, n)
+		}
+	}
+
+	changes := tracker.GetChanges("", "", "")
+	if len(changes) != len(tests) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(tests))
+	}
+	for i, tt := range tests {
+		c := changes[i]
+		if c.Operation != OpInsert || c.TableName != "archive" || c.ColumnName != "*" {
+			t.Errorf("%q: got %s %s.%s, want INSERT archive.*", tt.query, operationName(c.Operation), c.TableName, c.ColumnName)
+		}
+		if !reflect.DeepEqual(c.SourceTables, tt.sources) {
+			t.Errorf("%q: SourceTables = %q, want %q", tt.query, c.SourceTables, tt.sources)
+		}
+	}
+}
+
+func TestSetRedactionMasksStoredValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.jsonl")
+	tracker := New(path)
+	tracker.SetRedaction([]string{"Password"}, "***")
+
+	tracker.TrackQuery("UPDATE users SET password = 'hunter2' WHERE id = 1", 1, "mydb", "letmein", "hunter2")
+	tracker.TrackQuery("UPDATE users SET email = 'a@b.c' WHERE id = 1", 1, "mydb", "", "a@b.c")
+
+	changes := tracker.GetChanges("users", "password", "")
+	if len(changes) != 1 || changes[0].OldValue != "***" || changes[0].NewValue != "***" {
+		t.Fatalf("in-memory change = %+v, want masked values", changes)
+	}
+	if strings.Contains(changes[0].FullQuery, "hunter2") {
+		t.Errorf("FullQuery leaks value: %q", changes[0].FullQuery)
+	}
+	if got := tracker.GetChanges("users", "email", ""); len(got) != 1 || got[0].NewValue != "a@b.c" {
+		t.Errorf("unredacted column changed: %+v", got)
+	}
+	tracker.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read storage: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "letmein") {
+		t.Errorf("persisted file contains raw value:\n%s", data)
+	}
+
+	persisted, err := NewJSONLStorage(path).LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if persisted[0].OldValue != "***" || persisted[0].NewValue != "***" {
+		t.Errorf("persisted change = %+v, want masked values", persisted[0])
+	}
+}
+
+func TestSetRedactionMasksSiblingChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.jsonl")
+	tracker := New(path)
+	tracker.SetRedaction([]string{"password"}, "***")
+
+	tracker.TrackQuery("INSERT INTO users (name, password) VALUES ('bob', 'hunter2'), ('eve', 'swordfish')", 2, "mydb", "", "")
+	tracker.TrackQueryArgs(`UPDATE users SET "password" = ?, name = ? WHERE id = ?`, []interface{}{"s3cret", "al", 1}, 1, "mydb")
+	tracker.TrackQuery("DELETE FROM users WHERE password = 'letmein'", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'a' WHERE id = 1", 1, "mydb", "", "a")
+
+	for _, c := range tracker.GetChanges("users", "name", "") {
+		if c.FullQuery != "" {
+			t.Errorf("sibling %s change keeps FullQuery %q", c.ColumnName, c.FullQuery)
+		}
+	}
+	if got := tracker.GetChanges("users", "email", ""); len(got) != 1 || got[0].FullQuery != "UPDATE users SET email = 'a' WHERE id = 1" {
+		t.Errorf("query without a redacted column = %+v, want FullQuery unchanged", got)
+	}
+	tracker.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read storage: %v", err)
+	}
+	for _, secret := range []string{"hunter2", "swordfish", "s3cret", "letmein"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("persisted file contains %q:\n%s", secret, data)
+		}
+	}
+}
+
+func TestGzipJSONLStorageRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.jsonl.gz")
+
+	tracker := New(path)
+	if _, ok := tracker.storage.(*GzipJSONLStorage); !ok {
+		t.Fatalf("New(%q) storage = %T, want *GzipJSONLStorage", path, tracker.storage)
+	}
+	tracker.TrackQuery("UPDATE users SET email = 'a' WHERE id = 1", 1, "mydb", "", "a")
+	tracker.TrackQuery("DELETE FROM sessions WHERE id = 2", 1, "mydb", "", "")
+	want := tracker.GetChanges("", "", "")
+	tracker.Close()
+
+	// A second session appends a new gzip member to the same file
+	tracker = New(path)
+	tracker.TrackQuery("INSERT INTO logs (msg) VALUES ('hi')", 1, "mydb", "", "")
+	want = append(want, tracker.GetChanges("logs", "", "")...)
+	tracker.Close()
+
+	got, err := NewGzipJSONLStorage(path).LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d changes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].sameAs(want[i]) || got[i].TimestampNs != want[i].TimestampNs {
+			t.Errorf("change %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSetTracerEmitsSpanPerQuery(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tracker := New("")
+	defer tracker.Close()
+	tracker.SetTracer(provider.Tracer("sqltracker"))
+
+	tracker.TrackQuery("UPDATE users SET email = 'a', name = 'b' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("DELETE FROM sessions WHERE id = 2", 3, "authdb", "", "")
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	want := []struct {
+		name string
+		attr map[attribute.Key]attribute.Value
+	}{
+		{"UPDATE", map[attribute.Key]attribute.Value{
+			"db.sql.table":             attribute.StringValue("users"),
+			"sqltracker.column_count":  attribute.IntValue(2),
+			"sqltracker.rows_affected": attribute.IntValue(1),
+			"db.name":                  attribute.StringValue("mydb"),
+		}},
+		{"DELETE", map[attribute.Key]attribute.Value{
+			"db.sql.table":             attribute.StringValue("sessions"),
+			"sqltracker.column_count":  attribute.IntValue(1),
+			"sqltracker.rows_affected": attribute.IntValue(3),
+			"db.name":                  attribute.StringValue("authdb"),
+		}},
+	}
+	for i, w := range want {
+		if spans[i].Name() != w.name {
+			t.Errorf("span %d name = %q, want %q", i, spans[i].Name(), w.name)
+		}
+		got := make(map[attribute.Key]attribute.Value)
+		for _, kv := range spans[i].Attributes() {
+			got[kv.Key] = kv.Value
+		}
+		for key, value := range w.attr {
+			if got[key] != value {
+				t.Errorf("span %d attribute %s = %v, want %v", i, key, got[key].Emit(), value.Emit())
+			}
+		}
+	}
+}
+
+func TestClassifyOperation(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"insert into t (a) values (1)", OpInsert},
+		{"  Update t SET a = 1", OpUpdate},
+		{"delete from t", OpDelete},
+		{"select a from t", OpSelect},
+		{"CREATE TABLE t (id INTEGER)", OpCreate},
+		{"alter table t add column b text", OpAlter},
+		{"DROP TABLE IF EXISTS t", OpDrop},
+		{"TRUNCATE t", OpTruncate},
+		{"-- audit\nUPDATE t SET a = 1", OpUpdate},
+		{"/* hint */ /* more */ DELETE FROM t", OpDelete},
+		{"WITH recent AS (SELECT id FROM t WHERE ts > 5) SELECT * FROM recent", OpSelect},
+		{"WITH a AS (SELECT 1), b(x) AS (SELECT 2) DELETE FROM t WHERE id IN (SELECT x FROM b)", OpDelete},
+		{"EXPLAIN SELECT 1", OpUnknown},
+		{"updated_at", OpUnknown},
+		{"-- only a comment", OpUnknown},
+		{"", OpUnknown},
+	}
+	for _, tt := range tests {
+		if got := ClassifyOperation(tt.query); got != tt.want {
+			t.Errorf("ClassifyOperation(%q) = %s, want %s", tt.query, operationName(got), operationName(tt.want))
+		}
+	}
+
+	tracker := New("")
+	defer tracker.Close()
+	tracker.TrackQuery("-- migration 12\nupdate users set email = 'x' where id = 1", 1, "mydb", "", "")
+	if got := tracker.GetChanges("users", "email", "UPDATE"); len(got) != 1 {
+		t.Errorf("commented lowercase update tracked as %+v", tracker.GetChanges("", "", ""))
+	}
+}
+
+func TestTrackQueryWithCTEUsesMainStatement(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQuery("WITH stale AS (SELECT id FROM sessions WHERE ts < 5) UPDATE users SET active = 0 WHERE id = 7", 1, "mydb", "", "")
+	got := tracker.GetChanges("", "", "")
+	if len(got) != 1 {
+		t.Fatalf("tracked %+v, want one change", got)
+	}
+	if c := got[0]; c.TableName != "users" || c.ColumnName != "active" || c.Where["id"] != "7" || len(c.Where) != 1 {
+		t.Errorf("change = %+v, want users.active with where id=7", c)
+	}
+	if !strings.HasPrefix(got[0].FullQuery, "WITH stale AS") {
+		t.Errorf("FullQuery = %q, want the whole statement", got[0].FullQuery)
+	}
+
+	tracker.TrackQuery("WITH old AS (SELECT id FROM archive) DELETE FROM orders WHERE id = 3", 1, "mydb", "", "")
+	if got := tracker.GetChanges("orders", "", "DELETE"); len(got) != 1 || got[0].Where["id"] != "3" {
+		t.Errorf("CTE delete tracked as %+v, want one orders delete with where id=3", tracker.GetChanges("", "", "DELETE"))
+	}
+}
+
+func TestPreviewQueryDoesNotRecord(t *testing.T) {
+	storage := &memoryStorage{}
+	tracker := NewWithStorage(storage)
+	defer tracker.Close()
+
+	fired := 0
+	tracker.OnChange(func(SQLChange) { fired++ })
+
+	preview := tracker.PreviewQuery("UPDATE users SET email = 'x', name = 'y' WHERE id = 3", "mydb")
+	if len(preview) != 2 {
+		t.Fatalf("PreviewQuery returned %d changes, want 2", len(preview))
+	}
+	for i, column := range []string{"email", "name"} {
+		c := preview[i]
+		if c.TableName != "users" || c.ColumnName != column || c.Operation != OpUpdate || c.Database != "mydb" || c.Where["id"] != "3" {
+			t.Errorf("preview[%d] = %+v, want users.%s update", i, c, column)
+		}
+	}
+
+	if len(tracker.changes) != 0 || storage.appends != 0 || fired != 0 {
+		t.Errorf("PreviewQuery recorded: %d changes, %d appends, %d callbacks", len(tracker.changes), storage.appends, fired)
+	}
+}
+
+func TestPreviewQueryUsesRowCache(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+	tracker.SetRowCache(true)
+	tracker.TrackQuery("SELECT email FROM users WHERE id = 1", 1, "mydb", "", "a@example.com")
+
+	query := "UPDATE users SET email = 'b@example.com' WHERE id = 1"
+	preview := tracker.PreviewQuery(query, "mydb")
+	if len(preview) != 1 || preview[0].OldValue != "a@example.com" {
+		t.Fatalf("preview = %+v, want OldValue from the row cache", preview)
+	}
+
+	// Previewing leaves the cache as it was
+	tracker.PreviewQuery("UPDATE users SET email = 'z@example.com' WHERE id = 1", "mydb")
+	tracker.TrackQuery(query, 1, "mydb", "", "")
+	if got := tracker.GetChanges("users", "email", "UPDATE")[0].OldValue; got != preview[0].OldValue {
+		t.Errorf("tracked OldValue = %q, previewed %q", got, preview[0].OldValue)
+	}
+}
+
+func TestSetTrackedColumnsFiltersChanges(t *testing.T) {
+	storage := &memoryStorage{}
+	tracker := NewWithStorage(storage)
+	defer tracker.Close()
+	tracker.SetTrackedColumns([]string{"users.Email"})
+
+	query := "UPDATE users SET name = 'a', email = 'b', age = 3 WHERE id = 1"
+	if n := tracker.TrackQuery(query, 1, "mydb", "", ""); n != 1 {
+		t.Fatalf("TrackQuery kept %d changes, want 1", n)
+	}
+	if changes := tracker.GetChanges("", "", ""); len(changes) != 1 || changes[0].ColumnName != "email" {
+		t.Errorf("retained %+v, want only users.email", changes)
+	}
+	if storage.appends != 1 {
+		t.Errorf("persisted %d changes, want 1", storage.appends)
+	}
+
+	tracker.SetTrackedColumns([]string{"age"})
+	tracker.TrackQuery("UPDATE accounts SET age = 4, name = 'c' WHERE id = 2", 1, "mydb", "", "")
+	if changes := tracker.GetChanges("accounts", "", ""); len(changes) != 1 || changes[0].ColumnName != "age" {
+		t.Errorf("bare column allow-list retained %+v, want accounts.age", changes)
+	}
+
+	tracker.SetTrackedColumns(nil)
+	if n := tracker.TrackQuery(query, 1, "mydb", "", ""); n != 3 {
+		t.Errorf("empty allow-list kept %d changes, want 3", n)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	same := [][2]string{
+		{"SELECT * FROM users WHERE id=1", "select *  from users where id = 2"},
+		{"UPDATE users SET email = 'a@b.c' WHERE id = 1", "update users set email='x' where id=99"},
+		{"SELECT a FROM t WHERE id IN (1, 2, 3)", "SELECT a FROM t WHERE id in (7)"},
+		{"-- note\nDELETE FROM t WHERE x = 1.5", "DELETE FROM t WHERE x = 2"},
+		{"SELECT * FROM t /* c */ WHERE a = 1 -- x", "SELECT * FROM t WHERE a = 2"},
+		{"SELECT * FROM t WHERE a = 'it''s' -- trailing\nAND b = 1", "SELECT * FROM t WHERE a = 'x' AND b = 2"},
+	}
+	for _, pair := range same {
+		if a, b := Fingerprint(pair[0]), Fingerprint(pair[1]); a != b {
+			t.Errorf("Fingerprint(%q) = %q, Fingerprint(%q) = %q, want equal", pair[0], a, pair[1], b)
+		}
+	}
+
+	different := [][2]string{
+		{"SELECT * FROM users WHERE id = 1", "SELECT * FROM users WHERE email = 'x'"},
+		{"SELECT * FROM users WHERE id = 1", "SELECT * FROM orders WHERE id = 1"},
+		{"SELECT * FROM users WHERE id = 1", "SELECT * FROM users WHERE id > 1"},
+		{"SELECT a FROM t WHERE id IN (1, 2)", "SELECT a FROM t WHERE id IN (SELECT id FROM u)"},
+	}
+	for _, pair := range different {
+		if a, b := Fingerprint(pair[0]), Fingerprint(pair[1]); a == b {
+			t.Errorf("Fingerprint(%q) and Fingerprint(%q) both %q, want different", pair[0], pair[1], a)
+		}
+	}
+
+	if got, want := Fingerprint("select count(*) from t where id in (1,2) and name='x'"), "SELECT count(*) FROM t WHERE id IN (?) AND name = ?"; got != want {
+		t.Errorf("Fingerprint = %q, want %q", got, want)
+	}
+	if got, want := Fingerprint("SELECT * FROM t /* c */ WHERE a = 'it''s' -- x"), "SELECT * FROM t WHERE a = ?"; got != want {
+		t.Errorf("Fingerprint = %q, want %q", got, want)
+	}
+}
+
+func TestGroupByFingerprint(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQuery("UPDATE users SET email = 'a' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'b' WHERE id = 2", 1, "mydb", "", "")
+	tracker.TrackQuery("DELETE FROM users WHERE id = 3", 1, "mydb", "", "")
+
+	groups := tracker.GroupByFingerprint()
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %v", len(groups), groups)
+	}
+	updates := groups["UPDATE users SET email = ? WHERE id = ?"]
+	if len(updates) != 2 || updates[0].Where["id"] != "1" || updates[1].Where["id"] != "2" {
+		t.Errorf("update group = %+v, want both updates in order", updates)
+	}
+}
+
+func TestNoOpUpdatesCanBeExcluded(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQuery("UPDATE users SET email = 'a@b.c' WHERE id = 1", 1, "mydb", "a@b.c", "a@b.c")
+	tracker.TrackQuery("UPDATE users SET email = 'x@y.z' WHERE id = 1", 1, "mydb", "a@b.c", "x@y.z")
+	tracker.TrackQuery("UPDATE users SET email = 'q', name = 'q' WHERE id = 1", 1, "mydb", "q", "q")
+
+	all := tracker.GetChanges("users", "email", "")
+	if len(all) != 3 {
+		t.Fatalf("got %d changes, want 3", len(all))
+	}
+	if !all[0].NoOp || all[1].NoOp || all[2].NoOp {
+		t.Errorf("NoOp flags = %v %v %v, want only the first set", all[0].NoOp, all[1].NoOp, all[2].NoOp)
+	}
+
+	mutations := tracker.GetChangesExcludingNoOps("users", "email", "")
+	if len(mutations) != 2 || mutations[0].NewValue != "x@y.z" {
+		t.Errorf("GetChangesExcludingNoOps = %+v, want the two real updates", mutations)
+	}
+}
+
+func TestSetMaxQueryLengthTruncatesStoredQuery(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+	tracker.SetMaxQueryLength(32)
+
+	var cols, vals []string
+	for i := 0; i < 50; i++ {
+		cols = append(cols, "col"+strconv.Itoa(i))
+		vals = append(vals, "'"+strings.Repeat("v", 100)+"'")
+	}
+	query := "INSERT INTO wide (" + strings.Join(cols, ", ") + ") VALUES (" + strings.Join(vals, ", ") + ")"
+
+	if n := tracker.TrackQuery(query, 1, "mydb", "", ""); n != 50 {
+		t.Fatalf("TrackQuery kept %d changes, want 50", n)
+	}
+
+	changes := tracker.GetChanges("wide", "", "")
+	last := changes[len(changes)-1]
+	if last.ColumnName != "col49" || last.NewValue != strings.Repeat("v", 100) {
+		t.Errorf("last change = %s=%q, want col49 parsed from the full query", last.ColumnName, last.NewValue)
+	}
+	if !last.QueryTruncated || last.FullQuery != query[:32]+"…" {
+		t.Errorf("FullQuery = %q (truncated %v), want the first 32 bytes and a marker", last.FullQuery, last.QueryTruncated)
+	}
+
+	tracker.TrackQuery("DELETE FROM t WHERE id = 1", 1, "mydb", "", "")
+	if short := tracker.GetChanges("t", "", ""); short[0].QueryTruncated {
+		t.Error("short query flagged as truncated")
+	}
+}
+
+func TestMergeSummaries(t *testing.T) {
+	a := New("")
+	defer a.Close()
+	a.TrackQuery("UPDATE users SET email = 'x' WHERE id = 1", 1, "tenant_a", "", "")
+	a.TrackQuery("DELETE FROM sessions WHERE id = 1", 1, "tenant_a", "", "")
+
+	b := New("")
+	defer b.Close()
+	b.TrackQuery("UPDATE users SET email = 'y', name = 'z' WHERE id = 2", 1, "tenant_b", "", "")
+	b.TrackQuery("INSERT INTO audit (msg) VALUES ('hi')", 1, "tenant_b", "", "")
+
+	merged := MergeSummaries(a.GetSummary(), nil, b.GetSummary())
+
+	if merged.TotalChanges != 5 || merged.Update != 3 || merged.Delete != 1 || merged.Insert != 1 || merged.Select != 0 {
+		t.Errorf("counts = %+v, want 5 total: 3 update, 1 delete, 1 insert", merged)
+	}
+	wantTables := map[string]int{"users": 3, "sessions": 1, "audit": 1}
+	if !reflect.DeepEqual(merged.Tables, wantTables) {
+		t.Errorf("Tables = %v, want %v", merged.Tables, wantTables)
+	}
+	wantColumns := []string{"audit.msg", "sessions.*", "users.email", "users.name"}
+	if !reflect.DeepEqual(merged.Columns, wantColumns) {
+		t.Errorf("Columns = %v, want %v", merged.Columns, wantColumns)
+	}
+}
+
+func TestDiffSummaries(t *testing.T) {
+	yesterday := New("")
+	defer yesterday.Close()
+	yesterday.TrackQuery("UPDATE users SET email = 'x' WHERE id = 1", 1, "mydb", "", "")
+	yesterday.TrackQuery("DELETE FROM sessions WHERE id = 1", 1, "mydb", "", "")
+	yesterday.TrackQuery("DELETE FROM sessions WHERE id = 2", 1, "mydb", "", "")
+
+	today := New("")
+	defer today.Close()
+	today.TrackQuery("UPDATE users SET email = 'y', name = 'z' WHERE id = 2", 1, "mydb", "", "")
+	today.TrackQuery("UPDATE users SET email = 'w' WHERE id = 3", 1, "mydb", "", "")
+	today.TrackQuery("INSERT INTO audit (msg) VALUES ('hi')", 1, "mydb", "", "")
+
+	delta := DiffSummaries(yesterday.GetSummary(), today.GetSummary())
+
+	if delta.TotalChanges != 1 || delta.Update != 2 || delta.Delete != -2 || delta.Insert != 1 || delta.Select != 0 {
+		t.Errorf("operation deltas = %+v, want total 1: update +2, delete -2, insert +1", delta)
+	}
+	wantTables := map[string]int{"users": 2, "sessions": -2, "audit": 1}
+	if !reflect.DeepEqual(delta.Tables, wantTables) {
+		t.Errorf("Tables = %v, want %v", delta.Tables, wantTables)
+	}
+	if !reflect.DeepEqual(delta.NewTables, []string{"audit"}) || !reflect.DeepEqual(delta.GoneTables, []string{"sessions"}) {
+		t.Errorf("NewTables, GoneTables = %v, %v, want [audit], [sessions]", delta.NewTables, delta.GoneTables)
+	}
+	if want := []string{"audit.msg", "users.name"}; !reflect.DeepEqual(delta.AddedColumns, want) {
+		t.Errorf("AddedColumns = %v, want %v", delta.AddedColumns, want)
+	}
+	if want := []string{"sessions.*"}; !reflect.DeepEqual(delta.RemovedColumns, want) {
+		t.Errorf("RemovedColumns = %v, want %v", delta.RemovedColumns, want)
+	}
+}
+
+func TestIterateStopsEarlyWithoutAllocating(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	for i := 0; i < 10; i++ {
+		tracker.TrackQuery("UPDATE users SET age = "+strconv.Itoa(i)+" WHERE id = 1", 1, "mydb", "", strconv.Itoa(i))
+	}
+
+	visited := 0
+	tracker.Iterate(func(change SQLChange) bool {
+		visited++
+		return change.NewValue != "3"
+	})
+	if visited != 4 {
+		t.Errorf("visited %d changes, want 4 before stopping at age 3", visited)
+	}
+
+	count := 0
+	f := func(change SQLChange) bool {
+		count++
+		return true
+	}
+	if allocs := testing.AllocsPerRun(100, func() { tracker.Iterate(f) }); allocs != 0 {
+		t.Errorf("Iterate allocated %.1f times per run, want 0", allocs)
+	}
+}
+
+func TestSQLChangeJSONOperationEncodings(t *testing.T) {
+	want := SQLChange{TableName: "users", ColumnName: "email", Operation: OpUpdate, NewValue: "x@y.z"}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"operation":"UPDATE"`) {
+		t.Errorf("Marshal = %s, want the operation as a string", data)
+	}
+
+	for _, input := range []string{
+		string(data),
+		`{"TableName":"users","ColumnName":"email","Operation":2,"NewValue":"x@y.z"}`,
+		`{"TableName":"users","ColumnName":"email","operation":"UPDATE","NewValue":"x@y.z"}`,
+	} {
+		var got SQLChange
+		if err := json.Unmarshal([]byte(input), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", input, got, want)
+		}
+	}
+
+	var got SQLChange
+	if err := json.Unmarshal([]byte(`{"Operation":"UPSERT"}`), &got); err == nil {
+		t.Error("Unmarshal accepted an unknown operation name")
+	}
+}
+
+func TestTailChangesFollowsAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	storage := NewJSONLStorage(path)
+	defer storage.Close()
+	if err := storage.Append(SQLChange{TableName: "old", ColumnName: "skipped"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := TailChanges(ctx, path)
+	if err != nil {
+		t.Fatalf("TailChanges: %v", err)
+	}
+
+	if err := storage.Append(SQLChange{TableName: "users", ColumnName: "email"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the second change in two halves, as a slow writer might
+	line, _ := json.Marshal(SQLChange{TableName: "users", ColumnName: "name"})
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write(line[:10])
+	time.Sleep(2 * tailPollInterval)
+	f.Write(append(line[10:], '\n'))
+
+	for _, want := range []string{"email", "name"} {
+		select {
+		case change := <-changes:
+			if change.TableName != "users" || change.ColumnName != want {
+				t.Errorf("got %s.%s, want users.%s", change.TableName, change.ColumnName, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for users.%s", want)
+		}
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestTrackQueryArgsBindsPlaceholders(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQueryArgs("UPDATE users SET email = $1 WHERE id = $2", []interface{}{"o'brien@x.io", 42}, 1, "mydb")
+	tracker.TrackQueryArgs("UPDATE users SET name = ?, active = ? WHERE id = ?", []interface{}{"Ann", true, int64(7)}, 1, "mydb")
+	tracker.TrackQueryArgs("INSERT INTO users (name, note) VALUES (?, ?)", []interface{}{"Bob", nil}, 1, "mydb")
+
+	changes := tracker.GetChanges("users", "", "")
+	want := []struct {
+		column, value, id string
+	}{
+		{"email", "o'brien@x.io", "42"},
+		{"name", "Ann", "7"},
+		{"active", "TRUE", "7"},
+		{"name", "Bob", ""},
+		{"note", "NULL", ""},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(changes), len(want), changes)
+	}
+	for i, w := range want {
+		c := changes[i]
+		if c.ColumnName != w.column || c.NewValue != w.value || c.Where["id"] != w.id {
+			t.Errorf("changes[%d] = %s=%q where id=%q, want %s=%q where id=%q",
+				i, c.ColumnName, c.NewValue, c.Where["id"], w.column, w.value, w.id)
+		}
+	}
+}
+
+func TestTrackQueryArgsLeavesExpressionsEmpty(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQueryArgs("UPDATE counters SET n = n + 1, touched_at = NOW(), label = ? WHERE id = ?", []interface{}{"x", 3}, 1, "mydb")
+
+	want := map[string]string{"n": "", "touched_at": "", "label": "x"}
+	changes := tracker.GetChanges("counters", "", "UPDATE")
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(changes), len(want), changes)
+	}
+	for _, c := range changes {
+		if c.NewValue != want[c.ColumnName] {
+			t.Errorf("%s NewValue = %q, want %q", c.ColumnName, c.NewValue, want[c.ColumnName])
+		}
+	}
+}
+
+func TestTrackQueryArgsCountMismatch(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	for _, query := range []string{
+		"UPDATE users SET email = $1 WHERE id = $2",
+		"UPDATE users SET email = ? WHERE id = ?",
+	} {
+		tracker.TrackQueryArgs(query, []interface{}{"a@b.c"}, 1, "mydb")
+	}
+
+	changes := tracker.GetChanges("", "", "")
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+	for _, c := range changes {
+		if !c.ParseError || c.Operation != OpUnknown {
+			t.Errorf("change %+v not flagged as a parse error", c)
+		}
+	}
+	if n := tracker.ParseErrors(); n != 2 {
+		t.Errorf("ParseErrors() = %d, want 2", n)
+	}
+}
+
+func TestTrackQueryDeletePredicate(t *testing.T) {
+	tests := []struct {
+		query     string
+		predicate string
+		fullTable bool
+	}{
+		{"DELETE FROM users", "", true},
+		{"DELETE FROM users;", "", true},
+		{"DELETE FROM users WHERE last_login < '2020-01-01' LIMIT 100", "last_login < '2020-01-01'", false},
+	}
+
+	for _, tt := range tests {
+		tracker := New("")
+		if n := tracker.TrackQuery(tt.query, 42, "mydb", "", ""); n != 1 {
+			t.Fatalf("%q: TrackQuery = %d, want 1", tt.query, n)
+		}
+
+		got := tracker.GetChanges("", "", "")[0]
+		if got.Predicate != tt.predicate || got.FullTableDelete != tt.fullTable || got.RowsAffected != 42 {
+			t.Errorf("%q: Predicate = %q, FullTableDelete = %v, RowsAffected = %d, want %q, %v, 42",
+				tt.query, got.Predicate, got.FullTableDelete, got.RowsAffected, tt.predicate, tt.fullTable)
+		}
+		tracker.Close()
+	}
+
+	tracker := New("")
+	defer tracker.Close()
+	tracker.TrackQuery("UPDATE users SET name = 'x'", 1, "mydb", "", "x")
+	if got := tracker.GetChanges("", "", "")[0]; got.FullTableDelete || got.Predicate != "" {
+		t.Errorf("UPDATE change = %+v, want no DELETE audit fields", got)
+	}
+}
+
+func TestHistogramBucketsByMinute(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := base
+	tracker.SetClock(func() time.Time { return at })
+
+	track := func(offset time.Duration, query string) {
+		at = base.Add(offset)
+		tracker.TrackQuery(query, 1, "mydb", "", "")
+	}
+	track(61*time.Second, "DELETE FROM sessions WHERE id = 2")
+	track(5*time.Second, "UPDATE users SET email = 'a' WHERE id = 1")
+	track(59*time.Second, "INSERT INTO users (name) VALUES ('b')")
+	track(90*time.Second, "UPDATE users SET email = 'c' WHERE id = 1")
+	track(30*time.Second, "UPDATE users SET name = 'd' WHERE id = 1")
+
+	got := tracker.Histogram(time.Minute)
+	if len(got) != 2 {
+		t.Fatalf("Histogram returned %d buckets, want 2: %+v", len(got), got)
+	}
+
+	want := []Bucket{
+		{Start: base, Count: 3, ByOp: map[int]int{OpUpdate: 2, OpInsert: 1}},
+		{Start: base.Add(time.Minute), Count: 2, ByOp: map[int]int{OpUpdate: 1, OpDelete: 1}},
+	}
+	for i, w := range want {
+		if !got[i].Start.Equal(w.Start) || got[i].Count != w.Count || !reflect.DeepEqual(got[i].ByOp, w.ByOp) {
+			t.Errorf("bucket %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+
+	if got := tracker.Histogram(0); got != nil {
+		t.Errorf("Histogram(0) = %v, want nil", got)
+	}
+}
+
+func TestTrackQueryDDL(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tests := []struct {
+		query  string
+		op     int
+		object string
+	}{
+		{"CREATE TABLE IF NOT EXISTS audit (id INTEGER, msg TEXT)", OpCreate, "audit"},
+		{"ALTER TABLE users ADD COLUMN age INTEGER", OpAlter, "users"},
+		{"DROP TABLE `sessions`;", OpDrop, "sessions"},
+		{"TRUNCATE TABLE events", OpTruncate, "events"},
+		{"CREATE OR REPLACE VIEW active_users AS SELECT * FROM users", OpCreate, "active_users"},
+	}
+	for _, tt := range tests {
+		if n := tracker.TrackQuery(tt.query, 0, "mydb", "", ""); n != 1 {
+			t.Fatalf("%q: TrackQuery = %d, want 1", tt.query, n)
+		}
+		got := tracker.GetChanges("", "", "")
+		change := got[len(got)-1]
+		if change.Operation != tt.op || change.TableName != tt.object || change.ColumnName != "" || change.ParseError {
+			t.Errorf("%q: change = %s %q.%q (parse error %v), want %s %q with no column",
+				tt.query, operationName(change.Operation), change.TableName, change.ColumnName, change.ParseError, operationName(tt.op), tt.object)
+		}
+	}
+
+	summary := tracker.GetSummary()
+	if summary.Create != 2 || summary.Alter != 1 || summary.Drop != 1 || summary.Truncate != 1 {
+		t.Errorf("summary = %+v, want 2 create, 1 alter, 1 drop, 1 truncate", summary)
+	}
+	if len(summary.Columns) != 0 {
+		t.Errorf("Columns = %v, want none for DDL", summary.Columns)
+	}
+	if got := tracker.GetChanges("", "", "TRUNCATE"); len(got) != 1 {
+		t.Errorf("GetChanges by TRUNCATE = %d changes, want 1", len(got))
+	}
+}
+
+func TestRowCacheInfersUpdateOldValue(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	// Without the cache nothing is inferred
+	tracker.TrackQuery("SELECT email FROM users WHERE id = 1", 1, "mydb", "", "a@example.com")
+	tracker.TrackQuery("UPDATE users SET email = 'b@example.com' WHERE id = 1", 1, "mydb", "", "")
+	if got := tracker.GetChanges("users", "email", "UPDATE")[0].OldValue; got != "" {
+		t.Fatalf("OldValue without the row cache = %q, want empty", got)
+	}
+
+	tracker.SetRowCache(true)
+	tracker.TrackQuery("SELECT email FROM users WHERE id = 1", 1, "mydb", "", "a@example.com")
+	tracker.TrackQuery("UPDATE users SET email = 'b@example.com' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'c@example.com' WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET email = 'd@example.com' WHERE id = 2", 1, "mydb", "", "")
+
+	tracker.TrackQuery("INSERT INTO users (id, name) VALUES (3, 'carol')", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET name = 'caroline' WHERE id = 3", 1, "mydb", "", "")
+
+	updates := tracker.GetChanges("users", "", "UPDATE")
+	var got []string
+	for _, change := range updates[1:] {
+		got = append(got, change.OldValue)
+	}
+	want := []string{"a@example.com", "b@example.com", "", "carol"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inferred old values = %q, want %q", got, want)
+	}
+}
+
+func TestRowCacheSkipsDroppedAndRedactedColumns(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+	tracker.SetRowCache(true)
+	tracker.SetTrackedColumns([]string{"email", "ssn", "id"})
+	tracker.SetRedaction([]string{"ssn"}, "***")
+
+	tracker.TrackQuery("INSERT INTO users (id, email, name, ssn) VALUES (1, 'a@example.com', 'ann', '123-45')", 1, "mydb", "", "")
+	tracker.TrackQuery("SELECT email FROM users WHERE ssn = '123-45'", 1, "mydb", "", "a@example.com")
+
+	tracker.mu.RLock()
+	for key, row := range tracker.rowCache {
+		for column, value := range row {
+			if column == "name" || column == "ssn" || strings.Contains(key, "123-45") || value == "123-45" {
+				t.Errorf("row cache holds %s[%s] = %q", key, column, value)
+			}
+		}
+	}
+	tracker.mu.RUnlock()
+
+	tracker.TrackQuery("UPDATE users SET email = 'b@example.com' WHERE id = 1", 1, "mydb", "", "")
+	if got := tracker.GetChanges("users", "email", "UPDATE")[0].OldValue; got != "a@example.com" {
+		t.Errorf("OldValue of a kept column = %q, want a@example.com", got)
+	}
+}
+
+func TestGroupByRow(t *testing.T) {
+	tracker := New("")
+	defer tracker.Close()
+
+	tracker.TrackQuery("UPDATE users SET email = 'a' WHERE id = 1", 1, "mydb", "", "a")
+	tracker.TrackQuery("UPDATE users SET email = 'b' WHERE id = 2", 1, "mydb", "", "b")
+	tracker.TrackQuery("UPDATE users SET name = 'c' WHERE id = 1", 1, "mydb", "", "c")
+	tracker.TrackQuery("INSERT INTO users (name) VALUES ('d')", 1, "mydb", "", "")
+	tracker.TrackQuery("DELETE FROM users WHERE id = 1", 1, "mydb", "", "")
+	tracker.TrackQuery("UPDATE users SET name = 'e' WHERE id > 5", 1, "mydb", "", "e")
+
+	groups := tracker.GroupByRow()
+
+	var got []string
+	for _, change := range groups["users|id=1"] {
+		got = append(got, operationName(change.Operation)+" "+change.ColumnName)
+	}
+	if want := []string{"UPDATE email", "UPDATE name", "DELETE *"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users|id=1 = %v, want %v", got, want)
+	}
+	if n := len(groups["users|id=2"]); n != 1 {
+		t.Errorf("users|id=2 has %d changes, want 1", n)
+	}
+	if n := len(groups["users|*"]); n != 2 {
+		t.Errorf("users|* has %d changes, want the insert and the range update", n)
+	}
+	if len(groups) != 3 {
+		t.Errorf("got %d groups, want 3: %v", len(groups), groups)
+	}
+}
+
+func TestToJSONPatch(t *testing.T) {
+	tests := []struct {
+		query string
+		value string
+		want  []map[string]interface{}
+	}{
+		{"UPDATE users SET email = 'a@b.c' WHERE id = 1", "a@b.c",
+			[]map[string]interface{}{{"op": "replace", "path": "/users/email", "value": "a@b.c"}}},
+		{"INSERT INTO users (name) VALUES ('bob')", "bob",
+			[]map[string]interface{}{{"op": "add", "path": "/users/name", "value": "bob"}}},
+		{"DELETE FROM users WHERE id = 1", "",
+			[]map[string]interface{}{{"op": "remove", "path": "/users"}}},
+	}
+
+	for _, tt := range tests {
+		tracker := New("")
+		tracker.TrackQuery(tt.query, 1, "mydb", "", tt.value)
+		patch, err := tracker.GetChanges("", "", "")[0].ToJSONPatch()
+		tracker.Close()
+		if err != nil {
+			t.Fatalf("%q: ToJSONPatch: %v", tt.query, err)
+		}
+
+		var got []map[string]interface{}
+		if err := json.Unmarshal(patch, &got); err != nil {
+			t.Fatalf("%q: patch %s is not a JSON array: %v", tt.query, patch, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: patch = %s, want %v", tt.query, patch, tt.want)
+		}
+	}
+
+	escaped, _ := SQLChange{TableName: "a/b", ColumnName: "c~d", Operation: OpUpdate}.ToJSONPatch()
+	if want := `[{"op":"replace","path":"/a~1b/c~0d","value":""}]`; string(escaped) != want {
+		t.Errorf("escaped patch = %s, want %s", escaped, want)
+	}
+
+	if _, err := (SQLChange{TableName: "users", Operation: OpSelect}).ToJSONPatch(); err == nil {
+		t.Error("ToJSONPatch on a SELECT succeeded, want an error")
+	}
+}
+
+func TestChangesToJSONPatch(t *testing.T) {
+	changes := []SQLChange{
+		{TableName: "users", ColumnName: "name", Operation: OpInsert, NewValue: "bob"},
+		{TableName: "users", ColumnName: "name", Operation: OpUpdate, NewValue: "rob"},
+		{TableName: "users", ColumnName: "*", Operation: OpDelete},
+	}
+
+	patch, err := ChangesToJSONPatch(changes)
+	if err != nil {
+		t.Fatalf("ChangesToJSONPatch: %v", err)
+	}
+	want := `[{"op":"add","path":"/users/name","value":"bob"},` +
+		`{"op":"replace","path":"/users/name","value":"rob"},` +
+		`{"op":"remove","path":"/users"}]`
+	if string(patch) != want {
+		t.Errorf("patch = %s, want %s", patch, want)
+	}
+
+	if patch, err := ChangesToJSONPatch(nil); err != nil || string(patch) != "[]" {
+		t.Errorf("empty patch = %s, %v, want []", patch, err)
+	}
+	if _, err := ChangesToJSONPatch(append(changes, SQLChange{Operation: OpDrop})); err == nil {
+		t.Error("ChangesToJSONPatch with a DROP succeeded, want an error")
+	}
+}
+
+func TestTrackQueryInferredType(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"UPDATE users SET name = 'bob' WHERE id = 1", "string"},
+		{"UPDATE users SET zip = '02139' WHERE id = 1", "string"},
+		{"UPDATE users SET age = 42 WHERE id = 1", "int"},
+		{"UPDATE users SET age = -7", "int"},
+		{"UPDATE users SET score = 3.5", "float"},
+		{"UPDATE users SET score = 1e3", "float"},
+		{"UPDATE users SET email = NULL", "null"},
+		{"UPDATE users SET email = null", "null"},
+		{"UPDATE users SET active = TRUE", "bool"},
+		{"UPDATE users SET active = false", "bool"},
+		{"UPDATE users SET age = age + 1", ""},
+		{"UPDATE users SET age = ?", ""},
+		{"UPDATE users SET score = Inf", ""},
+		{"INSERT INTO users (name) VALUES ('42')", "string"},
+		{"INSERT INTO users (age) VALUES (42)", "int"},
+		{"INSERT INTO users (score) VALUES (0.25)", "float"},
+		{"INSERT INTO users (email) VALUES (NULL)", "null"},
+		{"INSERT INTO users (active) VALUES (TRUE)", "bool"},
+		{"DELETE FROM users WHERE id = 1", ""},
+	}
+
+	for _, tt := range tests {
+		tracker := New("")
+		if n := tracker.TrackQuery(tt.query, 1, "mydb", "", ""); n != 1 {
+			t.Fatalf("%q: TrackQuery = %d, want 1", tt.query, n)
+		}
+		if got := tracker.GetChanges("", "", "")[0].InferredType; got != tt.want {
+			t.Errorf("%q: InferredType = %q, want %q", tt.query, got, tt.want)
+		}
+		tracker.Close()
+	}
+
+	// The literal is typed even when the caller supplies the value
+	tracker := New("")
+	defer tracker.Close()
+	tracker.TrackQuery("INSERT INTO users (id, name) VALUES (7, 'ann')", 1, "mydb", "", "bound")
+	want := map[string]string{"id": "int", "name": "string"}
+	for _, change := range tracker.GetChanges("", "", "") {
+		if change.NewValue != "bound" || change.InferredType != want[change.ColumnName] {
+			t.Errorf("%s change = %q typed %q, want \"bound\" typed %q",
+				change.ColumnName, change.NewValue, change.InferredType, want[change.ColumnName])
+		}
+	}
+}
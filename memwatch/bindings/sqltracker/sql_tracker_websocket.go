@@ -0,0 +1,115 @@
+package sqltracker
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsSubscriberBuffer is how many changes a websocket client may lag behind
+// before further changes are dropped for it
+const wsSubscriberBuffer = 64
+
+// upgrader keeps gorilla's default origin check, which refuses browser
+// handshakes from pages served by another host
+var upgrader = websocket.Upgrader{}
+
+// wsHub fans tracked changes out to connected websocket clients
+type wsHub struct {
+	mu   sync.Mutex
+	subs map[*wsSubscriber]struct{}
+}
+
+type wsSubscriber struct {
+	table string
+	ch    chan SQLChange
+}
+
+// hub returns the tracker's websocket hub, hooking it into OnChange on first use
+func (t *SQLTracker) hub() *wsHub {
+	t.wsOnce.Do(func() {
+		t.ws = &wsHub{subs: make(map[*wsSubscriber]struct{})}
+		t.OnChange(t.ws.publish)
+	})
+	return t.ws
+}
+
+// publish delivers a change to every matching subscriber without blocking
+func (h *wsHub) publish(change SQLChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if sub.table != "" && sub.table != change.TableName {
+			continue
+		}
+		select {
+		case sub.ch <- change:
+		default:
+			log.Printf("sqltracker: websocket client too slow, dropping change to %s.%s", change.TableName, change.ColumnName)
+		}
+	}
+}
+
+func (h *wsHub) add(sub *wsSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[sub] = struct{}{}
+}
+
+func (h *wsHub) remove(sub *wsSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, sub)
+}
+
+// WebSocketHandler returns a handler that upgrades the connection and streams
+// every newly tracked change as a JSON message. The optional ?table= query
+// parameter restricts the stream to a single table. Clients that fall behind
+// lose changes rather than slowing down TrackQuery. Handshakes carrying an
+// Origin header for a different host are refused with 403 Forbidden, so
+// other web pages cannot read the stream.
+func (t *SQLTracker) WebSocketHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub := t.hub()
+		sub := &wsSubscriber{
+			table: r.URL.Query().Get("table"),
+			ch:    make(chan SQLChange, wsSubscriberBuffer),
+		}
+
+		// Subscribe before the handshake completes so no change tracked
+		// after the client connects is missed
+		hub.add(sub)
+		defer hub.remove(sub)
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// The read loop only exists to notice the client going away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case change := <-sub.ch:
+				if err := conn.WriteJSON(change); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
@@ -116,8 +116,7 @@ func monitorSensitiveOperations(tracker *sqltracker.SQLTracker) {
 		
 		// Check for sensitive operations
 		for _, field := range sensitiveFields {
-			// Simple substring check (real implementation would use SQL parser)
-			if contains(query, field) {
+			if sqltracker.QueryMentions(query, field) {
 				fmt.Printf("🚨 ALERT: Sensitive field '%s' accessed!\n", field)
 				fmt.Printf("   Query: %s\n", query)
 			}
@@ -140,17 +139,6 @@ func operationName(op int) string {
 	}
 }
 
-func contains(s, substr string) bool {
-	for i := 0; i < len(s); i++ {
-		if i+len(substr) <= len(s) {
-			if s[i:i+len(substr)] == substr {
-				return true
-			}
-		}
-	}
-	return false
-}
-
 /*
  * Build with:
  *   go build -o sql_tracker_example_go examples/sql_tracker_example_go.go
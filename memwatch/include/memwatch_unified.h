@@ -23,6 +23,10 @@ extern "C" {
 typedef uint32_t memwatch_region_id;
 typedef uint32_t memwatch_adapter_id;
 
+/* Adapter id reserved for file-backed shared mappings, so their events
+ * can be told apart from those of regions registered by a binding. */
+#define MEMWATCH_ADAPTER_MMAP 255u
+
 /* Change event - same structure across all languages */
 typedef struct {
     uint32_t seq;
@@ -94,6 +98,31 @@ void memwatch_shutdown(void);
 memwatch_region_id memwatch_watch(uint64_t addr, size_t size, 
                                   const char *name, void *user_data);
 
+/**
+ * Watch a memory region on behalf of a specific adapter
+ * 
+ * Same as memwatch_watch, but events for the region carry adapter_id so
+ * the worker and callbacks can route them. memwatch_watch uses adapter 0.
+ * 
+ * Returns: region_id > 0 on success, 0 on error
+ */
+memwatch_region_id memwatch_watch_adapter(uint64_t addr, size_t size,
+                                          const char *name,
+                                          memwatch_adapter_id adapter_id,
+                                          void *user_data);
+
+/**
+ * Watch a memory region on behalf of an adapter, reporting why it failed
+ * 
+ * Same as memwatch_watch_adapter, but returns MEMWATCH_OK and stores the
+ * new region's id in *out_region_id, or returns one of the MEMWATCH_ERR_*
+ * codes: NOT_INIT before memwatch_init, INVALID_ADDR for a zero address or
+ * size and NO_MEMORY when no region slot is free.
+ */
+int memwatch_watch_status(uint64_t addr, size_t size, const char *name,
+                          memwatch_adapter_id adapter_id, void *user_data,
+                          memwatch_region_id *out_region_id);
+
 /**
  * Stop watching a region
  * 
@@ -152,6 +181,10 @@ typedef struct {
     uint32_t mprotect_page_count;  /* Linux/macOS only */
     uint32_t worker_thread_id;
     uint64_t worker_cycles;
+    
+    /* Current ring buffer fill level; appended to keep the layout above */
+    uint32_t ring_occupancy;
+    uint32_t ring_capacity;
 } memwatch_stats_t;
 
 int memwatch_get_stats(memwatch_stats_t *out_stats);
@@ -38,6 +38,7 @@ typedef struct {
     size_t size;
     const char *name;
     uint32_t region_id;
+    uint32_t adapter_id;
     void *user_data;
     bool active;
     uint8_t *last_snapshot;
@@ -99,6 +100,7 @@ static void* worker_thread_fn(void *arg) {
                     memwatch_change_event_t event = {
                         .seq = tail,
                         .timestamp_ns = evt->timestamp_ns,
+                        .adapter_id = region->adapter_id,
                         .region_id = region->region_id,
                         .variable_name = region->name,
                         .old_preview = (uint8_t *)"changed",
@@ -180,8 +182,27 @@ void memwatch_shutdown(void) {
 
 memwatch_region_id memwatch_watch(uint64_t addr, size_t size, 
                                   const char *name, void *user_data) {
+    return memwatch_watch_adapter(addr, size, name, 0, user_data);
+}
+
+memwatch_region_id memwatch_watch_adapter(uint64_t addr, size_t size,
+                                          const char *name,
+                                          memwatch_adapter_id adapter_id,
+                                          void *user_data) {
+    memwatch_region_id region_id = 0;
+    memwatch_watch_status(addr, size, name, adapter_id, user_data, &region_id);
+    return region_id;
+}
+
+int memwatch_watch_status(uint64_t addr, size_t size, const char *name,
+                          memwatch_adapter_id adapter_id, void *user_data,
+                          memwatch_region_id *out_region_id) {
+    *out_region_id = 0;
     if (!g_state.ring) {
-        return 0;
+        return MEMWATCH_ERR_NOT_INIT;
+    }
+    if (addr == 0 || size == 0) {
+        return MEMWATCH_ERR_INVALID_ADDR;
     }
     
     pthread_mutex_lock(&g_state.regions_mutex);
@@ -194,6 +215,7 @@ memwatch_region_id memwatch_watch(uint64_t addr, size_t size,
             g_state.regions[i].size = size;
             g_state.regions[i].name = name;
             g_state.regions[i].region_id = region_id;
+            g_state.regions[i].adapter_id = adapter_id;
             g_state.regions[i].user_data = user_data;
             g_state.regions[i].last_snapshot = malloc(size < 256 ? size : 256);
             g_state.regions[i].active = true;
@@ -203,7 +225,11 @@ memwatch_region_id memwatch_watch(uint64_t addr, size_t size,
     
     pthread_mutex_unlock(&g_state.regions_mutex);
     
-    return region_id;
+    if (region_id == 0) {
+        return MEMWATCH_ERR_NO_MEMORY;
+    }
+    *out_region_id = region_id;
+    return MEMWATCH_OK;
 }
 
 bool memwatch_unwatch(memwatch_region_id region_id) {
@@ -253,6 +279,13 @@ int memwatch_get_stats(memwatch_stats_t *out_stats) {
     pthread_mutex_unlock(&g_state.regions_mutex);
     
     out_stats->total_events = atomic_load(&g_state.ring_head);
+    /* Load tail first: it never passes head, so a head read afterwards is
+     * at least as new. Clamp anyway in case of a wrap. */
+    unsigned tail = atomic_load(&g_state.ring_tail);
+    unsigned head = atomic_load(&g_state.ring_head);
+    unsigned occupancy = head >= tail ? head - tail : 0;
+    out_stats->ring_occupancy = occupancy > RING_CAPACITY ? RING_CAPACITY : occupancy;
+    out_stats->ring_capacity = RING_CAPACITY;
     
     return 0;
 }
\ No newline at end of file